@@ -10,8 +10,7 @@ function Comparison() {
   const isMobile = useIsMobile()
   const [period1, setPeriod1] = useState([dayjs().subtract(60, 'days'), dayjs().subtract(31, 'days')])
   const [period2, setPeriod2] = useState([dayjs().subtract(30, 'days'), dayjs()])
-  const [data1, setData1] = useState(null)
-  const [data2, setData2] = useState(null)
+  const [comparison, setComparison] = useState(null)
   const [loading, setLoading] = useState(false)
   const [availableDates, setAvailableDates] = useState([])
 
@@ -25,20 +24,17 @@ function Comparison() {
       .catch(err => console.error('Failed to fetch available dates:', err))
   }, [])
 
-  const fetchData = async (from, to) => {
-    const response = await fetch(`/api/summary?from=${from}&to=${to}`)
-    return await response.json()
-  }
-
   const handleCompare = async () => {
     setLoading(true)
     try {
-      const [d1, d2] = await Promise.all([
-        fetchData(period1[0].format('YYYY-MM-DD'), period1[1].format('YYYY-MM-DD')),
-        fetchData(period2[0].format('YYYY-MM-DD'), period2[1].format('YYYY-MM-DD')),
-      ])
-      setData1(d1)
-      setData2(d2)
+      const params = new URLSearchParams({
+        a_from: period1[0].format('YYYY-MM-DD'),
+        a_to: period1[1].format('YYYY-MM-DD'),
+        b_from: period2[0].format('YYYY-MM-DD'),
+        b_to: period2[1].format('YYYY-MM-DD'),
+      })
+      const response = await fetch(`/api/compare?${params}`)
+      setComparison(await response.json())
     } catch (error) {
       console.error('Failed to compare:', error)
     } finally {
@@ -52,34 +48,32 @@ function Comparison() {
   }
 
   const renderComparison = () => {
-    if (!data1 || !data2) return null
+    if (!comparison) return null
 
-    const downloadedChange = calculateChange(data1.total_downloaded, data2.total_downloaded)
-    const uploadedChange = calculateChange(data1.total_uploaded, data2.total_uploaded)
-    const totalChange = calculateChange(
-      data1.total_downloaded + data1.total_uploaded,
-      data2.total_downloaded + data2.total_uploaded
-    )
+    const { a: data1, b: data2, total } = comparison
+    const downloadedChange = calculateChange(data1.downloaded, data2.downloaded)
+    const uploadedChange = calculateChange(data1.uploaded, data2.uploaded)
+    const totalChange = total.delta_percent ?? calculateChange(total.a, total.b)
 
     const stats = [
       {
         label: 'Downloaded',
-        value1: data1.total_downloaded,
-        value2: data2.total_downloaded,
+        value1: data1.downloaded,
+        value2: data2.downloaded,
         change: downloadedChange,
         gradient: 'linear-gradient(135deg, #4facfe, #00f2fe)',
       },
       {
         label: 'Uploaded',
-        value1: data1.total_uploaded,
-        value2: data2.total_uploaded,
+        value1: data1.uploaded,
+        value2: data2.uploaded,
         change: uploadedChange,
         gradient: 'linear-gradient(135deg, #fa709a, #fee140)',
       },
       {
         label: 'Total Traffic',
-        value1: data1.total_downloaded + data1.total_uploaded,
-        value2: data2.total_downloaded + data2.total_uploaded,
+        value1: total.a,
+        value2: total.b,
         change: totalChange,
         gradient: 'linear-gradient(135deg, #667eea, #764ba2)',
       },
@@ -321,7 +315,7 @@ function Comparison() {
                 P1 Days
               </div>
               <div style={{ fontSize: isMobile ? '20px' : '24px', fontWeight: '700', color: '#00f5ff' }}>
-                {data1.days.length}
+                {data1.data_days}
               </div>
             </div>
 
@@ -330,7 +324,7 @@ function Comparison() {
                 P2 Days
               </div>
               <div style={{ fontSize: isMobile ? '20px' : '24px', fontWeight: '700', color: '#00f5ff' }}>
-                {data2.days.length}
+                {data2.data_days}
               </div>
             </div>
 
@@ -339,7 +333,7 @@ function Comparison() {
                 Avg P1
               </div>
               <div style={{ fontSize: isMobile ? '20px' : '24px', fontWeight: '700', color: '#fa709a' }}>
-                {formatBytes((data1.total_downloaded + data1.total_uploaded) / data1.days.length)}
+                {formatBytes(data1.per_day)}
               </div>
             </div>
 
@@ -348,7 +342,7 @@ function Comparison() {
                 Avg P2
               </div>
               <div style={{ fontSize: isMobile ? '20px' : '24px', fontWeight: '700', color: '#fa709a' }}>
-                {formatBytes((data2.total_downloaded + data2.total_uploaded) / data2.days.length)}
+                {formatBytes(data2.per_day)}
               </div>
             </div>
           </div>
@@ -84,6 +84,30 @@ function Dashboard({ dateRange }) {
     setCurrentPage(1)
   }, [dateRange])
 
+  // Live updates: refresh summary when a day inside the selected range changes
+  useEffect(() => {
+    if (typeof EventSource === 'undefined') return
+
+    const from = dateRange[0].format('YYYY-MM-DD')
+    const to = dateRange[1].format('YYYY-MM-DD')
+    const source = new EventSource('/api/events')
+    let refreshTimer = null
+
+    source.addEventListener('day_updated', (event) => {
+      const day = JSON.parse(event.data)
+      if (day.date < from || day.date > to) return
+
+      // Debounce: a batch of reloaded files triggers a single refresh
+      clearTimeout(refreshTimer)
+      refreshTimer = setTimeout(refreshSummary, 1000)
+    })
+
+    return () => {
+      clearTimeout(refreshTimer)
+      source.close()
+    }
+  }, [dateRange])
+
   useEffect(() => {
     if (modalVisible) {
       const scrollY = window.scrollY
@@ -116,6 +140,19 @@ function Dashboard({ dateRange }) {
     }
   }
 
+  // Silent refresh without the loading state (used by live updates)
+  const refreshSummary = async () => {
+    try {
+      const from = dateRange[0].format('YYYY-MM-DD')
+      const to = dateRange[1].format('YYYY-MM-DD')
+      const response = await fetch(`/api/summary?from=${from}&to=${to}`)
+      const data = await response.json()
+      setSummary(data)
+    } catch (error) {
+      console.error('Failed to refresh summary:', error)
+    }
+  }
+
   const fetchProtocols = async (mac) => {
     setProtocolsLoading(true)
     try {
@@ -2,6 +2,7 @@ package achievements
 
 import (
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"nlbw-ui/internal/aggregator"
@@ -13,18 +14,24 @@ import (
 type Calculator struct {
 	cache      *cache.Cache
 	aggregator *aggregator.Aggregator
-	config     *config.Config
+	config     atomic.Pointer[config.Config]
 	achCache   *AchievementCache
 }
 
 // NewCalculator создаёт новый калькулятор достижений
 func NewCalculator(c *cache.Cache, agg *aggregator.Aggregator, cfg *config.Config) *Calculator {
-	return &Calculator{
+	calc := &Calculator{
 		cache:      c,
 		aggregator: agg,
-		config:     cfg,
 		achCache:   NewAchievementCache(),
 	}
+	calc.config.Store(cfg)
+	return calc
+}
+
+// SetConfig атомарно подменяет конфиг (используется при перезагрузке)
+func (c *Calculator) SetConfig(cfg *config.Config) {
+	c.config.Store(cfg)
 }
 
 // GetNetworkAchievements возвращает все достижения для всей сети
@@ -388,7 +395,7 @@ func (c *Calculator) checkFTPTrafficAchievement(achievement Achievement) Achieve
 	totalTraffic := uint64(0)
 
 	calendarData := c.aggregator.GetCalendarData(nil)
-	allData := c.cache.GetAll()
+	allData := c.aggregator.Data()
 
 	for _, day := range calendarData {
 		for path, data := range allData {
@@ -447,7 +454,7 @@ func (c *Calculator) checkHTTPTrafficAchievement(achievement Achievement) Achiev
 	totalTraffic := uint64(0)
 
 	calendarData := c.aggregator.GetCalendarData(nil)
-	allData := c.cache.GetAll()
+	allData := c.aggregator.Data()
 
 	for _, day := range calendarData {
 		for path, data := range allData {
@@ -511,7 +518,7 @@ func (c *Calculator) checkQUICTrafficAchievement(achievement Achievement) Achiev
 	totalTraffic := uint64(0)
 
 	calendarData := c.aggregator.GetCalendarData(nil)
-	allData := c.cache.GetAll()
+	allData := c.aggregator.Data()
 
 	for _, day := range calendarData {
 		for path, data := range allData {
@@ -575,7 +582,7 @@ func (c *Calculator) checkDNSQueriesAchievement(achievement Achievement) Achieve
 	totalQueries := uint64(0)
 
 	calendarData := c.aggregator.GetCalendarData(nil)
-	allData := c.cache.GetAll()
+	allData := c.aggregator.Data()
 
 	for _, day := range calendarData {
 		for path, data := range allData {
@@ -634,7 +641,7 @@ func (c *Calculator) checkGhostMacAchievement(achievement Achievement) Achieveme
 	var unlockedDate *time.Time
 
 	calendarData := c.aggregator.GetCalendarData(nil)
-	allData := c.cache.GetAll()
+	allData := c.aggregator.Data()
 
 	for _, day := range calendarData {
 		if status.Unlocked {
@@ -836,7 +843,7 @@ func (c *Calculator) checkICMPPacketsAchievement(achievement Achievement) Achiev
 	totalPackets := uint64(0)
 
 	calendarData := c.aggregator.GetCalendarData(nil)
-	allData := c.cache.GetAll()
+	allData := c.aggregator.Data()
 
 	for _, day := range calendarData {
 		// Находим данные для этого дня
@@ -898,7 +905,7 @@ func (c *Calculator) checkSSHTrafficAchievement(achievement Achievement) Achieve
 	totalTraffic := uint64(0)
 
 	calendarData := c.aggregator.GetCalendarData(nil)
-	allData := c.cache.GetAll()
+	allData := c.aggregator.Data()
 
 	for _, day := range calendarData {
 		// Находим данные для этого дня
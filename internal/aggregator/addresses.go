@@ -0,0 +1,172 @@
+package aggregator
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// AddressStats - трафик устройства с одного IP адреса
+type AddressStats struct {
+	IP         string `json:"ip"`
+	Family     int    `json:"family"` // 4 или 6
+	Downloaded uint64 `json:"downloaded"`
+	Uploaded   uint64 `json:"uploaded"`
+	FirstSeen  string `json:"first_seen"` // YYYY-MM-DD
+	LastSeen   string `json:"last_seen"`  // YYYY-MM-DD
+}
+
+// addAddress учитывает трафик строки за день date в списке адресов устройства
+func (d *DeviceStats) addAddress(date, ip string, family int, rx, tx uint64) {
+	d.mergeAddress(AddressStats{
+		IP:         ip,
+		Family:     family,
+		Downloaded: rx,
+		Uploaded:   tx,
+		FirstSeen:  date,
+		LastSeen:   date,
+	})
+}
+
+// mergeAddress добавляет адрес или суммирует с уже известным.
+// У устройства обычно несколько адресов, поэтому хватает линейного поиска.
+func (d *DeviceStats) mergeAddress(addr AddressStats) {
+	for i := range d.Addresses {
+		existing := &d.Addresses[i]
+		if existing.IP != addr.IP {
+			continue
+		}
+		existing.Downloaded += addr.Downloaded
+		existing.Uploaded += addr.Uploaded
+		if addr.FirstSeen < existing.FirstSeen {
+			existing.FirstSeen = addr.FirstSeen
+		}
+		if addr.LastSeen > existing.LastSeen {
+			existing.LastSeen = addr.LastSeen
+		}
+		return
+	}
+	d.Addresses = append(d.Addresses, addr)
+}
+
+// mergeAddresses добавляет адреса другого DeviceStats и обновляет основной IP
+func (d *DeviceStats) mergeAddresses(other *DeviceStats) {
+	for _, addr := range other.Addresses {
+		d.mergeAddress(addr)
+	}
+	d.sortAddresses()
+}
+
+// sortAddresses упорядочивает адреса (свежие и активные первыми)
+// и делает основным IP самый свежий из них
+func (d *DeviceStats) sortAddresses() {
+	sort.Slice(d.Addresses, func(i, j int) bool {
+		a, b := d.Addresses[i], d.Addresses[j]
+		if a.LastSeen != b.LastSeen {
+			return a.LastSeen > b.LastSeen
+		}
+		if a.Downloaded+a.Uploaded != b.Downloaded+b.Uploaded {
+			return a.Downloaded+a.Uploaded > b.Downloaded+b.Uploaded
+		}
+		return a.IP < b.IP
+	})
+	if len(d.Addresses) > 0 {
+		d.IP = d.Addresses[0].IP
+	}
+}
+
+// IPDevice - устройство, которому принадлежал адрес
+type IPDevice struct {
+	MAC          string   `json:"mac"`
+	FriendlyName string   `json:"friendly_name"`
+	Downloaded   uint64   `json:"downloaded"`
+	Uploaded     uint64   `json:"uploaded"`
+	FirstSeen    string   `json:"first_seen"`
+	LastSeen     string   `json:"last_seen"`
+	Dates        []string `json:"dates"` // дни, когда адрес был у устройства
+}
+
+// IPLookup - ответ /api/ip/{addr}
+type IPLookup struct {
+	IP      string     `json:"ip"`
+	From    string     `json:"from,omitempty"`
+	To      string     `json:"to,omitempty"`
+	Devices []IPDevice `json:"devices"` // по убыванию last_seen
+}
+
+// NormalizeIP приводит адрес к канонической записи (как в колонке ip),
+// пустая строка - адрес некорректен
+func NormalizeIP(value string) string {
+	ip := net.ParseIP(strings.TrimSpace(value))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// LookupIP возвращает устройства, у которых был адрес ip в [from, to].
+// Пустые from и to - за всё время.
+func (a *Aggregator) LookupIP(ip, from, to string) *IPLookup {
+	result := &IPLookup{IP: ip, From: from, To: to, Devices: make([]IPDevice, 0)}
+
+	days := a.days()
+	if from != "" && to != "" {
+		days = a.daysInRange(from, to)
+	}
+
+	devices := make(map[string]*IPDevice)
+	for date, entries := range days {
+		for _, data := range datasets(entries) {
+			for _, row := range data.Data {
+				if len(row) < 11 || row[4].(string) != ip {
+					continue
+				}
+
+				mac := strings.ToLower(row[3].(string))
+				device, exists := devices[mac]
+				if !exists {
+					device = &IPDevice{
+						MAC:          mac,
+						FriendlyName: a.FriendlyName(mac),
+						FirstSeen:    date,
+						LastSeen:     date,
+					}
+					devices[mac] = device
+				}
+				device.Downloaded += row[6].(uint64)
+				device.Uploaded += row[8].(uint64)
+				if date < device.FirstSeen {
+					device.FirstSeen = date
+				}
+				if date > device.LastSeen {
+					device.LastSeen = date
+				}
+				if !containsString(device.Dates, date) {
+					device.Dates = append(device.Dates, date)
+				}
+			}
+		}
+	}
+
+	for _, device := range devices {
+		sort.Strings(device.Dates)
+		result.Devices = append(result.Devices, *device)
+	}
+	sort.Slice(result.Devices, func(i, j int) bool {
+		if result.Devices[i].LastSeen != result.Devices[j].LastSeen {
+			return result.Devices[i].LastSeen > result.Devices[j].LastSeen
+		}
+		return result.Devices[i].MAC < result.Devices[j].MAC
+	})
+
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package aggregator
+
+import (
+	"strings"
+	"testing"
+)
+
+// rowIP - строка с заданным IP
+func rowIP(mac, ip string, rx uint64) []interface{} {
+	r := row("TCP", 443, mac, rx, 0)
+	r[4] = ip
+	if strings.Contains(ip, ":") {
+		r[0] = 6
+	}
+	return r
+}
+
+func TestDeviceAddresses(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			rowIP("aa:aa:aa:aa:aa:aa", "192.168.1.10", 100),
+			rowIP("aa:aa:aa:aa:aa:aa", "fd00::aaaa:1234:5678", 50),
+		},
+		"20240105": {rowIP("aa:aa:aa:aa:aa:aa", "192.168.1.57", 10)},
+		"20240103": {rowIP("aa:aa:aa:aa:aa:aa", "192.168.1.10", 100)},
+	})
+
+	device := agg.GetSummary("2024-01-01", "2024-01-31").Devices["aa:aa:aa:aa:aa:aa"]
+	if len(device.Addresses) != 3 {
+		t.Fatalf("Expected 3 addresses, got %+v", device.Addresses)
+	}
+	if device.IP != "192.168.1.57" || device.Addresses[0].IP != "192.168.1.57" {
+		t.Errorf("Expected the most recent address first, got %s", device.IP)
+	}
+
+	v4 := device.Addresses[1]
+	if v4.IP != "192.168.1.10" || v4.Downloaded != 200 || v4.FirstSeen != "2024-01-01" || v4.LastSeen != "2024-01-03" {
+		t.Errorf("Unexpected address stats: %+v", v4)
+	}
+	if device.Addresses[2].Family != 6 {
+		t.Errorf("Expected IPv6 address, got %+v", device.Addresses[2])
+	}
+}
+
+func TestLookupIP(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {rowIP("aa:aa:aa:aa:aa:aa", "192.168.1.57", 100)},
+		"20240102": {rowIP("aa:aa:aa:aa:aa:aa", "192.168.1.57", 100)},
+		"20240110": {
+			rowIP("bb:bb:bb:bb:bb:bb", "192.168.1.57", 5),
+			rowIP("aa:aa:aa:aa:aa:aa", "192.168.1.10", 5),
+		},
+	})
+
+	all := agg.LookupIP("192.168.1.57", "", "")
+	if len(all.Devices) != 2 || all.Devices[0].MAC != "bb:bb:bb:bb:bb:bb" {
+		t.Fatalf("Unexpected lookup: %+v", all.Devices)
+	}
+	if a := all.Devices[1]; a.Downloaded != 200 || len(a.Dates) != 2 || a.FirstSeen != "2024-01-01" || a.LastSeen != "2024-01-02" {
+		t.Errorf("Unexpected device: %+v", a)
+	}
+
+	day := agg.LookupIP("192.168.1.57", "2024-01-02", "2024-01-02")
+	if len(day.Devices) != 1 || day.Devices[0].MAC != "aa:aa:aa:aa:aa:aa" {
+		t.Errorf("Unexpected lookup for a day: %+v", day.Devices)
+	}
+
+	if NormalizeIP("FD00:0::1") != "fd00::1" || NormalizeIP("nope") != "" {
+		t.Error("Unexpected NormalizeIP result")
+	}
+}
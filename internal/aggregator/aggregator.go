@@ -2,30 +2,45 @@ package aggregator
 
 import (
 	"fmt"
-	"path/filepath"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"nlbw-ui/internal/cache"
 	"nlbw-ui/internal/config"
 	"nlbw-ui/internal/converter"
+	"nlbw-ui/internal/services"
 )
 
 type DeviceStats struct {
-	MAC         string `json:"mac"`
-	FriendlyName string `json:"friendly_name"`
-	IP          string `json:"ip"`
-	Downloaded  uint64 `json:"downloaded"`
-	Uploaded    uint64 `json:"uploaded"`
-	RxPackets   uint64 `json:"rx_packets"`
-	TxPackets   uint64 `json:"tx_packets"`
-	Connections uint64 `json:"connections"`
+	MAC          string         `json:"mac"`
+	FriendlyName string         `json:"friendly_name"`
+	IP           string         `json:"ip"`
+	Downloaded   uint64         `json:"downloaded"`
+	Uploaded     uint64         `json:"uploaded"`
+	RxPackets    uint64         `json:"rx_packets"`
+	TxPackets    uint64         `json:"tx_packets"`
+	Connections  uint64         `json:"connections"`
+	Families     FamilySplit    `json:"families"`          // разбивка по IPv4/IPv6
+	Addresses    []AddressStats `json:"addresses"`         // все IP устройства, свежие первыми; ip - самый свежий
+	Sources      []string       `json:"sources,omitempty"` // источники, видевшие устройство (только в summary)
+}
+
+// addSource добавляет источник в список, если его там ещё нет
+func (d *DeviceStats) addSource(source string) {
+	for _, existing := range d.Sources {
+		if existing == source {
+			return
+		}
+	}
+	d.Sources = append(d.Sources, source)
 }
 
 type ProtocolStats struct {
 	Protocol    string `json:"protocol"`
 	Port        uint16 `json:"port"`
+	Service     string `json:"service,omitempty"` // известный сервис (HTTPS, DNS, ...)
 	Downloaded  uint64 `json:"downloaded"`
 	Uploaded    uint64 `json:"uploaded"`
 	RxPackets   uint64 `json:"rx_packets"`
@@ -37,6 +52,7 @@ type DayStats struct {
 	Date       string                  `json:"date"`
 	Downloaded uint64                  `json:"downloaded"`
 	Uploaded   uint64                  `json:"uploaded"`
+	Families   FamilySplit             `json:"families"`
 	Devices    map[string]*DeviceStats `json:"devices,omitempty"`
 }
 
@@ -47,34 +63,219 @@ type CalendarDay struct {
 	Uploaded   uint64 `json:"uploaded"`   // tx bytes
 }
 
+// SourceTotals - трафик одного источника за период
+type SourceTotals struct {
+	Downloaded uint64 `json:"downloaded"`
+	Uploaded   uint64 `json:"uploaded"`
+	Days       int    `json:"days"`
+}
+
+// Summary - агрегированная статистика за период (ответ /api/summary)
+type Summary struct {
+	From            string                   `json:"from"`
+	To              string                   `json:"to"`
+	TotalDownloaded uint64                   `json:"total_downloaded"`
+	TotalUploaded   uint64                   `json:"total_uploaded"`
+	Families        FamilySplit              `json:"families"` // IPv4/IPv6 всей сети
+	Devices         map[string]*DeviceStats  `json:"devices"`
+	Days            []DaySummary             `json:"days"`
+	Sources         map[string]*SourceTotals `json:"sources"`
+}
+
 type Aggregator struct {
-	cache  *cache.Cache
-	config *config.Config
+	cache   *cache.Cache
+	config  *atomic.Pointer[config.Config] // общий для всех представлений ForSource
+	source  string                         // пусто = все источники
+	filters []rowFilter                    // пусто = все строки (см. WithIPFilter, WithSegments)
+}
+
+// dayEntry - один файл за день вместе с источником
+type dayEntry struct {
+	source string
+	data   *converter.TrafficData
 }
 
 func New(c *cache.Cache, cfg *config.Config) *Aggregator {
-	return &Aggregator{
+	a := &Aggregator{
 		cache:  c,
-		config: cfg,
+		config: &atomic.Pointer[config.Config]{},
 	}
+	a.config.Store(cfg)
+	return a
 }
 
-// ExtractDateFromFilename извлекает дату из имени файла (YYYYMMDD.db.gz)
-func (a *Aggregator) ExtractDateFromFilename(filename string) string {
-	base := filepath.Base(filename)
-	dateStr := strings.TrimSuffix(base, ".db.gz")
+// ForSource возвращает представление агрегатора, видящее только один источник.
+// Пустое имя - все источники. Конфиг остаётся общим с исходным агрегатором.
+func (a *Aggregator) ForSource(source string) *Aggregator {
+	return &Aggregator{
+		cache:   a.cache,
+		config:  a.config,
+		source:  source,
+		filters: a.filters,
+	}
+}
+
+// rowFilter отбирает строки TrafficData для представлений агрегатора
+type rowFilter func(row []interface{}) bool
+
+// withRowFilter возвращает представление с дополнительным фильтром строк
+func (a *Aggregator) withRowFilter(filter rowFilter) *Aggregator {
+	view := a.ForSource(a.source)
+	view.filters = append(append([]rowFilter(nil), a.filters...), filter)
+	return view
+}
 
-	if len(dateStr) == 8 {
-		// YYYYMMDD -> YYYY-MM-DD
-		return dateStr[0:4] + "-" + dateStr[4:6] + "-" + dateStr[6:8]
+// filterRows возвращает копию данных только со строками, прошедшими все фильтры
+func (a *Aggregator) filterRows(data *converter.TrafficData) *converter.TrafficData {
+	filtered := &converter.TrafficData{
+		Columns: data.Columns,
+		Data:    make([][]interface{}, 0, len(data.Data)),
 	}
-	return dateStr
+rows:
+	for _, row := range data.Data {
+		if len(row) < 5 {
+			continue
+		}
+		for _, filter := range a.filters {
+			if !filter(row) {
+				continue rows
+			}
+		}
+		filtered.Data = append(filtered.Data, row)
+	}
+	return filtered
+}
+
+// Source возвращает имя источника представления (пусто = все)
+func (a *Aggregator) Source() string {
+	return a.source
+}
+
+// Config возвращает текущий конфиг
+func (a *Aggregator) Config() *config.Config {
+	return a.config.Load()
+}
+
+// Data возвращает файлы из кэша с учётом фильтров по источнику и строкам.
+// Один день источника может быть в кэше дважды (data_dir и архив) -
+// остаётся один вариант (см. preferDataset), чтобы трафик не удваивался.
+func (a *Aggregator) Data() map[string]*converter.TrafficData {
+	return a.selectData(a.cache.GetAll())
+}
+
+// selectData применяет к файлам из кэша фильтры представления (см. Data)
+func (a *Aggregator) selectData(allData map[string]*converter.TrafficData) map[string]*converter.TrafficData {
+	type sourceDay struct{ source, date string }
+	kept := make(map[sourceDay]string, len(allData))
+
+	for key, data := range allData {
+		source, _ := cache.SplitKey(key)
+		if a.source != "" && source != a.source {
+			delete(allData, key)
+			continue
+		}
+
+		day := sourceDay{source, a.ExtractDateFromFilename(key)}
+		other, exists := kept[day]
+		if !exists {
+			kept[day] = key
+			continue
+		}
+
+		if preferDataset(key, data, other, allData[other]) {
+			delete(allData, other)
+			kept[day] = key
+		} else {
+			delete(allData, key)
+		}
+	}
+
+	if len(a.filters) > 0 {
+		for key, data := range allData {
+			allData[key] = a.filterRows(data)
+		}
+	}
+	return allData
+}
+
+// preferDataset выбирает между двумя файлами одного дня источника:
+// настоящий файл лучше дня из помесячной сводки архива (у него нет расширения),
+// дальше - больше строк, при равенстве - детерминированно по ключу
+func preferDataset(key string, data *converter.TrafficData, otherKey string, other *converter.TrafficData) bool {
+	_, isFile := converter.TrimExtension(key)
+	_, otherIsFile := converter.TrimExtension(otherKey)
+	if isFile != otherIsFile {
+		return isFile
+	}
+	if len(data.Data) != len(other.Data) {
+		return len(data.Data) > len(other.Data)
+	}
+	return key < otherKey
+}
+
+// days группирует файлы по дате: за один день может быть по файлу от каждого источника
+func (a *Aggregator) days() map[string][]dayEntry {
+	return groupDays(a.Data())
+}
+
+// day возвращает файлы одной даты, не перебирая весь кэш
+func (a *Aggregator) day(date string) ([]dayEntry, bool) {
+	entries, ok := groupDays(a.selectData(a.cache.GetDate(date)))[date]
+	return entries, ok
+}
+
+func groupDays(data map[string]*converter.TrafficData) map[string][]dayEntry {
+	result := make(map[string][]dayEntry)
+	for key, dataset := range data {
+		source, _ := cache.SplitKey(key)
+		date := converter.DateFromFilename(key)
+		result[date] = append(result[date], dayEntry{source: source, data: dataset})
+	}
+	return result
+}
+
+// daysInRange возвращает дни из диапазона [from, to] (формат YYYY-MM-DD)
+func (a *Aggregator) daysInRange(from, to string) map[string][]dayEntry {
+	fromTime, _ := time.Parse("2006-01-02", from)
+	toTime, _ := time.Parse("2006-01-02", to)
+
+	result := a.days()
+	for date := range result {
+		fileTime, err := time.Parse("2006-01-02", date)
+		if err != nil || fileTime.Before(fromTime) || fileTime.After(toTime) {
+			delete(result, date)
+		}
+	}
+	return result
+}
+
+// datasets возвращает данные всех источников за день
+func datasets(entries []dayEntry) []*converter.TrafficData {
+	result := make([]*converter.TrafficData, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.data
+	}
+	return result
+}
+
+// SetConfig атомарно подменяет конфиг (используется при перезагрузке)
+func (a *Aggregator) SetConfig(cfg *config.Config) {
+	a.config.Store(cfg)
+}
+
+// FriendlyName возвращает имя устройства из текущего конфига
+func (a *Aggregator) FriendlyName(mac string) string {
+	return a.config.Load().GetFriendlyName(mac)
+}
+
+// ExtractDateFromFilename извлекает дату из имени файла (YYYYMMDD.db.gz, YYYYMMDD.json, ...)
+func (a *Aggregator) ExtractDateFromFilename(filename string) string {
+	return converter.DateFromFilename(filename)
 }
 
 // GetCalendarData возвращает данные для матрицы активности
 // Если macs не пуст, то фильтрует по устройствам
 func (a *Aggregator) GetCalendarData(macs []string) []CalendarDay {
-	allData := a.cache.GetAll()
 	result := make([]CalendarDay, 0)
 
 	// Создаём set для быстрого поиска MAC-адресов
@@ -84,16 +285,20 @@ func (a *Aggregator) GetCalendarData(macs []string) []CalendarDay {
 	}
 	filterByMacs := len(macs) > 0
 
-	for path, data := range allData {
-		date := a.ExtractDateFromFilename(path)
+	for date, entries := range a.days() {
 		var downloaded, uploaded uint64
 
-		if filterByMacs {
-			// Фильтруем только по выбранным устройствам
-			downloaded, uploaded = a.calculateTrafficSplitFiltered(data, macSet)
-		} else {
-			// Все устройства
-			downloaded, uploaded = a.calculateTrafficSplit(data)
+		for _, entry := range entries {
+			var rx, tx uint64
+			if filterByMacs {
+				// Фильтруем только по выбранным устройствам
+				rx, tx = a.calculateTrafficSplitFiltered(entry.data, macSet)
+			} else {
+				// Все устройства
+				rx, tx = a.calculateTrafficSplit(entry.data)
+			}
+			downloaded += rx
+			uploaded += tx
 		}
 		total := downloaded + uploaded
 
@@ -112,32 +317,42 @@ func (a *Aggregator) GetCalendarData(macs []string) []CalendarDay {
 	return result
 }
 
+// GetCalendarDay возвращает итоги одного дня в формате календаря
+func (a *Aggregator) GetCalendarDay(date string) *CalendarDay {
+	entries, ok := a.day(date)
+	if !ok {
+		return nil
+	}
+
+	day := &CalendarDay{Date: date}
+	for _, entry := range entries {
+		downloaded, uploaded := a.calculateTrafficSplit(entry.data)
+		day.Downloaded += downloaded
+		day.Uploaded += uploaded
+	}
+	day.Value = day.Downloaded + day.Uploaded
+
+	return day
+}
+
 // GetDayStats возвращает детальную статистику за конкретный день
 func (a *Aggregator) GetDayStats(date string) *DayStats {
-	allData := a.cache.GetAll()
-
-	for path, data := range allData {
-		fileDate := a.ExtractDateFromFilename(path)
-		if fileDate == date {
-			return a.aggregateDayData(date, data)
-		}
+	entries, ok := a.day(date)
+	if !ok {
+		return nil
 	}
 
-	return nil
+	return a.aggregateDayData(date, datasets(entries)...)
 }
 
 // GetDeviceProtocols возвращает разбивку по протоколам для устройства
 func (a *Aggregator) GetDeviceProtocols(date, mac string) []ProtocolStats {
-	allData := a.cache.GetAll()
-
-	for path, data := range allData {
-		fileDate := a.ExtractDateFromFilename(path)
-		if fileDate == date {
-			return a.aggregateDeviceProtocols(mac, data)
-		}
+	entries, ok := a.day(date)
+	if !ok {
+		return nil
 	}
 
-	return nil
+	return a.aggregateDeviceProtocols(mac, datasets(entries)...)
 }
 
 // DaySummary - облегчённая структура для списка дней (без devices)
@@ -148,100 +363,98 @@ type DaySummary struct {
 }
 
 // GetSummary возвращает агрегированную статистику за период
-// devices агрегируются за весь период, days содержит только даты и трафик
-func (a *Aggregator) GetSummary(from, to string) map[string]interface{} {
-	allData := a.cache.GetAll()
+// devices агрегируются за весь период, days содержит только даты и трафик.
+// Трафик разных источников суммируется без дедупликации, а sources
+// показывает вклад каждого источника.
+func (a *Aggregator) GetSummary(from, to string) *Summary {
+	summary := &Summary{
+		From:    from,
+		To:      to,
+		Devices: make(map[string]*DeviceStats),
+		Days:    make([]DaySummary, 0),
+		Sources: make(map[string]*SourceTotals),
+	}
 
-	var totalDownloaded, totalUploaded uint64
-	daySummaries := make([]DaySummary, 0)
-	aggregatedDevices := make(map[string]*DeviceStats)
+	for date, entries := range a.daysInRange(from, to) {
+		for _, entry := range entries {
+			downloaded, uploaded := a.calculateTrafficSplit(entry.data)
+			if _, exists := summary.Sources[entry.source]; !exists {
+				summary.Sources[entry.source] = &SourceTotals{}
+			}
+			totals := summary.Sources[entry.source]
+			totals.Downloaded += downloaded
+			totals.Uploaded += uploaded
+			totals.Days++
+		}
 
-	fromTime, _ := time.Parse("2006-01-02", from)
-	toTime, _ := time.Parse("2006-01-02", to)
+		dayData := a.aggregateDayData(date, datasets(entries)...)
+		summary.TotalDownloaded += dayData.Downloaded
+		summary.TotalUploaded += dayData.Uploaded
+		summary.Families.add(dayData.Families)
 
-	for path, data := range allData {
-		fileDate := a.ExtractDateFromFilename(path)
-		fileTime, err := time.Parse("2006-01-02", fileDate)
-		if err != nil {
-			continue
+		// Добавляем облегчённую запись дня
+		summary.Days = append(summary.Days, DaySummary{
+			Date:       dayData.Date,
+			Downloaded: dayData.Downloaded,
+			Uploaded:   dayData.Uploaded,
+		})
+
+		// Агрегируем devices за весь период
+		for mac, device := range dayData.Devices {
+			if _, exists := summary.Devices[mac]; !exists {
+				summary.Devices[mac] = &DeviceStats{
+					MAC:          device.MAC,
+					FriendlyName: device.FriendlyName,
+					IP:           device.IP,
+				}
+			}
+			agg := summary.Devices[mac]
+			agg.Downloaded += device.Downloaded
+			agg.Uploaded += device.Uploaded
+			agg.RxPackets += device.RxPackets
+			agg.TxPackets += device.TxPackets
+			agg.Connections += device.Connections
+			agg.Families.add(device.Families)
+			// Основной IP - самый свежий из адресов
+			agg.mergeAddresses(device)
 		}
 
-		if (fileTime.Equal(fromTime) || fileTime.After(fromTime)) &&
-		   (fileTime.Equal(toTime) || fileTime.Before(toTime)) {
-			dayData := a.aggregateDayData(fileDate, data)
-			totalDownloaded += dayData.Downloaded
-			totalUploaded += dayData.Uploaded
-
-			// Добавляем облегчённую запись дня
-			daySummaries = append(daySummaries, DaySummary{
-				Date:       dayData.Date,
-				Downloaded: dayData.Downloaded,
-				Uploaded:   dayData.Uploaded,
-			})
-
-			// Агрегируем devices за весь период
-			for mac, device := range dayData.Devices {
-				if _, exists := aggregatedDevices[mac]; !exists {
-					aggregatedDevices[mac] = &DeviceStats{
-						MAC:          device.MAC,
-						FriendlyName: device.FriendlyName,
-						IP:           device.IP,
-					}
+		// Помечаем, какие источники видели устройство
+		for _, entry := range entries {
+			for _, row := range entry.data.Data {
+				if len(row) < 11 {
+					continue
 				}
-				agg := aggregatedDevices[mac]
-				agg.Downloaded += device.Downloaded
-				agg.Uploaded += device.Uploaded
-				agg.RxPackets += device.RxPackets
-				agg.TxPackets += device.TxPackets
-				agg.Connections += device.Connections
-				// Обновляем IP на самый свежий
-				if device.IP != "" {
-					agg.IP = device.IP
+				if device, ok := summary.Devices[row[3].(string)]; ok {
+					device.addSource(entry.source)
 				}
 			}
 		}
 	}
 
-	sort.Slice(daySummaries, func(i, j int) bool {
-		return daySummaries[i].Date < daySummaries[j].Date
+	sort.Slice(summary.Days, func(i, j int) bool {
+		return summary.Days[i].Date < summary.Days[j].Date
 	})
-
-	return map[string]interface{}{
-		"from":             from,
-		"to":               to,
-		"total_downloaded": totalDownloaded,
-		"total_uploaded":   totalUploaded,
-		"devices":          aggregatedDevices,
-		"days":             daySummaries,
+	for _, device := range summary.Devices {
+		sort.Strings(device.Sources)
 	}
+
+	return summary
 }
 
 // GetTimeseries возвращает данные для графиков
 func (a *Aggregator) GetTimeseries(from, to string, macs []string) []DayStats {
-	allData := a.cache.GetAll()
 	result := make([]DayStats, 0)
 
-	fromTime, _ := time.Parse("2006-01-02", from)
-	toTime, _ := time.Parse("2006-01-02", to)
+	for date, entries := range a.daysInRange(from, to) {
+		dayData := a.aggregateDayData(date, datasets(entries)...)
 
-	for path, data := range allData {
-		fileDate := a.ExtractDateFromFilename(path)
-		fileTime, err := time.Parse("2006-01-02", fileDate)
-		if err != nil {
-			continue
+		// Фильтрация по устройствам если указаны
+		if len(macs) > 0 {
+			dayData = a.filterByDevices(dayData, macs)
 		}
 
-		if (fileTime.Equal(fromTime) || fileTime.After(fromTime)) &&
-		   (fileTime.Equal(toTime) || fileTime.Before(toTime)) {
-			dayData := a.aggregateDayData(fileDate, data)
-
-			// Фильтрация по устройствам если указаны
-			if len(macs) > 0 {
-				dayData = a.filterByDevices(dayData, macs)
-			}
-
-			result = append(result, *dayData)
-		}
+		result = append(result, *dayData)
 	}
 
 	sort.Slice(result, func(i, j int) bool {
@@ -292,78 +505,90 @@ func (a *Aggregator) calculateTrafficSplitFiltered(data *converter.TrafficData,
 	return downloaded, uploaded
 }
 
-func (a *Aggregator) aggregateDayData(date string, data *converter.TrafficData) *DayStats {
+func (a *Aggregator) aggregateDayData(date string, datasets ...*converter.TrafficData) *DayStats {
 	stats := &DayStats{
 		Date:    date,
 		Devices: make(map[string]*DeviceStats),
 	}
 
-	for _, row := range data.Data {
-		if len(row) < 11 {
-			continue
-		}
+	for _, data := range datasets {
+		for _, row := range data.Data {
+			if len(row) < 11 {
+				continue
+			}
 
-		mac := row[3].(string)
-		ip := row[4].(string)
-		rxBytes := row[6].(uint64)
-		rxPkts := row[7].(uint64)
-		txBytes := row[8].(uint64)
-		txPkts := row[9].(uint64)
-		conns := row[5].(uint64)
-
-		stats.Downloaded += rxBytes
-		stats.Uploaded += txBytes
-
-		if _, exists := stats.Devices[mac]; !exists {
-			stats.Devices[mac] = &DeviceStats{
-				MAC:          mac,
-				FriendlyName: a.config.GetFriendlyName(mac),
-				IP:           ip,
+			mac := row[3].(string)
+			ip := row[4].(string)
+			rxBytes := row[6].(uint64)
+			rxPkts := row[7].(uint64)
+			txBytes := row[8].(uint64)
+			txPkts := row[9].(uint64)
+			conns := row[5].(uint64)
+
+			stats.Downloaded += rxBytes
+			stats.Uploaded += txBytes
+			stats.Families.addRow(row)
+
+			if _, exists := stats.Devices[mac]; !exists {
+				stats.Devices[mac] = &DeviceStats{
+					MAC:          mac,
+					FriendlyName: a.config.Load().GetFriendlyName(mac),
+					IP:           ip,
+				}
 			}
+
+			device := stats.Devices[mac]
+			device.Downloaded += rxBytes
+			device.Uploaded += txBytes
+			device.RxPackets += rxPkts
+			device.TxPackets += txPkts
+			device.Connections += conns
+			device.Families.addRow(row)
+			device.addAddress(date, ip, row[0].(int), rxBytes, txBytes)
 		}
+	}
 
-		device := stats.Devices[mac]
-		device.Downloaded += rxBytes
-		device.Uploaded += txBytes
-		device.RxPackets += rxPkts
-		device.TxPackets += txPkts
-		device.Connections += conns
+	for _, device := range stats.Devices {
+		device.sortAddresses()
 	}
 
 	return stats
 }
 
-func (a *Aggregator) aggregateDeviceProtocols(mac string, data *converter.TrafficData) []ProtocolStats {
+func (a *Aggregator) aggregateDeviceProtocols(mac string, datasets ...*converter.TrafficData) []ProtocolStats {
 	protoMap := make(map[string]*ProtocolStats)
 	normalizedMAC := strings.ToLower(mac)
 
-	for _, row := range data.Data {
-		if len(row) < 11 {
-			continue
-		}
+	for _, data := range datasets {
+		for _, row := range data.Data {
+			if len(row) < 11 {
+				continue
+			}
 
-		rowMac := strings.ToLower(row[3].(string))
-		if rowMac != normalizedMAC {
-			continue
-		}
+			rowMac := strings.ToLower(row[3].(string))
+			if rowMac != normalizedMAC {
+				continue
+			}
 
-		proto := row[1].(string)
-		port := row[2].(uint16)
-		key := fmt.Sprintf("%s:%d", proto, port)
+			proto := row[1].(string)
+			port := row[2].(uint16)
+			key := fmt.Sprintf("%s:%d", proto, port)
 
-		if _, exists := protoMap[key]; !exists {
-			protoMap[key] = &ProtocolStats{
-				Protocol: proto,
-				Port:     port,
+			if _, exists := protoMap[key]; !exists {
+				protoMap[key] = &ProtocolStats{
+					Protocol: proto,
+					Port:     port,
+					Service:  services.Name(proto, port),
+				}
 			}
-		}
 
-		ps := protoMap[key]
-		ps.Downloaded += row[6].(uint64)
-		ps.Uploaded += row[8].(uint64)
-		ps.RxPackets += row[7].(uint64)
-		ps.TxPackets += row[9].(uint64)
-		ps.Connections += row[5].(uint64)
+			ps := protoMap[key]
+			ps.Downloaded += row[6].(uint64)
+			ps.Uploaded += row[8].(uint64)
+			ps.RxPackets += row[7].(uint64)
+			ps.TxPackets += row[9].(uint64)
+			ps.Connections += row[5].(uint64)
+		}
 	}
 
 	result := make([]ProtocolStats, 0, len(protoMap))
@@ -380,64 +605,12 @@ func (a *Aggregator) aggregateDeviceProtocols(mac string, data *converter.Traffi
 
 // GetDeviceProtocolsRange возвращает агрегированные протоколы устройства за диапазон дат
 func (a *Aggregator) GetDeviceProtocolsRange(from, to, mac string) []ProtocolStats {
-	allData := a.cache.GetAll()
-	protoMap := make(map[string]*ProtocolStats)
-
-	fromTime, _ := time.Parse("2006-01-02", from)
-	toTime, _ := time.Parse("2006-01-02", to)
-	normalizedMAC := strings.ToLower(mac)
-
-	for path, data := range allData {
-		fileDate := a.ExtractDateFromFilename(path)
-		fileTime, err := time.Parse("2006-01-02", fileDate)
-		if err != nil {
-			continue
-		}
-
-		if (fileTime.Equal(fromTime) || fileTime.After(fromTime)) &&
-		   (fileTime.Equal(toTime) || fileTime.Before(toTime)) {
-			// Агрегируем протоколы за этот день
-			for _, row := range data.Data {
-				if len(row) < 11 {
-					continue
-				}
-
-				rowMac := strings.ToLower(row[3].(string))
-				if rowMac != normalizedMAC {
-					continue
-				}
-
-				proto := row[1].(string)
-				port := row[2].(uint16)
-				key := fmt.Sprintf("%s:%d", proto, port)
-
-				if _, exists := protoMap[key]; !exists {
-					protoMap[key] = &ProtocolStats{
-						Protocol: proto,
-						Port:     port,
-					}
-				}
-
-				ps := protoMap[key]
-				ps.Downloaded += row[6].(uint64)
-				ps.Uploaded += row[8].(uint64)
-				ps.RxPackets += row[7].(uint64)
-				ps.TxPackets += row[9].(uint64)
-				ps.Connections += row[5].(uint64)
-			}
-		}
+	var all []*converter.TrafficData
+	for _, entries := range a.daysInRange(from, to) {
+		all = append(all, datasets(entries)...)
 	}
 
-	result := make([]ProtocolStats, 0, len(protoMap))
-	for _, ps := range protoMap {
-		result = append(result, *ps)
-	}
-
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Downloaded > result[j].Downloaded
-	})
-
-	return result
+	return a.aggregateDeviceProtocols(mac, all...)
 }
 
 func (a *Aggregator) filterByDevices(dayData *DayStats, macs []string) *DayStats {
@@ -455,6 +628,7 @@ func (a *Aggregator) filterByDevices(dayData *DayStats, macs []string) *DayStats
 		if macSet[strings.ToLower(mac)] {
 			filtered.Downloaded += device.Downloaded
 			filtered.Uploaded += device.Uploaded
+			filtered.Families.add(device.Families)
 			filtered.Devices[mac] = device
 		}
 	}
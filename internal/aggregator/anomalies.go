@@ -0,0 +1,282 @@
+package aggregator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Метрики аномалий
+const (
+	AnomalyDownloaded  = "downloaded"
+	AnomalyUploaded    = "uploaded"
+	AnomalyConnections = "connections"
+	AnomalyNewPorts    = "new_ports"
+)
+
+// Значения AnomalyOptions по умолчанию
+const (
+	DefaultAnomalyWindow    = 28
+	DefaultAnomalyThreshold = 6.0
+
+	anomalyMinHistory = 7       // дней в базовой линии, меньше - базовой линии нет
+	anomalyMinBytes   = 1 << 20 // отклонения меньше 1 МБ не считаются
+	anomalyMinConns   = 10      // то же для числа соединений
+	anomalyMinPort    = 1 << 20 // новый порт с меньшим трафиком не считается
+)
+
+// madScale приводит MAD к стандартному отклонению нормального распределения
+const madScale = 1.4826
+
+// AnomalyOptions - параметры DetectAnomalies
+type AnomalyOptions struct {
+	Window    int     // дней истории для базовой линии
+	Threshold float64 // порог робастной z-оценки (отклонение от медианы в MAD)
+}
+
+// Anomaly - необычный день устройства по одной метрике
+type Anomaly struct {
+	Date         string   `json:"date"`
+	MAC          string   `json:"mac"`
+	FriendlyName string   `json:"friendly_name"`
+	Metric       string   `json:"metric"` // downloaded, uploaded, connections или new_ports
+	Value        uint64   `json:"value"`  // для new_ports - трафик новых портов
+	Median       float64  `json:"median"` // базовая линия за окно
+	MAD          float64  `json:"mad"`
+	Score        float64  `json:"score"`           // во сколько раз отклонение больше разброса, для new_ports - 0
+	Ports        []string `json:"ports,omitempty"` // только для new_ports: TCP/8443, ...
+	HistoryDays  int      `json:"history_days"`    // дней в базовой линии, включая дни без трафика
+}
+
+// AnomalyReport - ответ /api/anomalies
+type AnomalyReport struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Window    int       `json:"window"`
+	Threshold float64   `json:"threshold"`
+	Anomalies []Anomaly `json:"anomalies"` // по дате, затем по убыванию score
+}
+
+// deviceDay - дневные показатели устройства
+type deviceDay struct {
+	downloaded  uint64
+	uploaded    uint64
+	connections uint64
+	ports       map[string]uint64 // proto/port -> трафик
+}
+
+// ValidateAnomalyOptions проверяет окно и порог
+func ValidateAnomalyOptions(opts AnomalyOptions) error {
+	if opts.Window < anomalyMinHistory || opts.Window > 365 {
+		return fmt.Errorf("window must be between %d and 365 days", anomalyMinHistory)
+	}
+	if opts.Threshold <= 0 {
+		return fmt.Errorf("threshold must be positive")
+	}
+	return nil
+}
+
+// DetectAnomalies ищет дни в [from, to], когда трафик, число соединений или
+// набор портов устройства резко отличались от его базовой линии - медианы и
+// MAD за предыдущие opts.Window дней. Учитывается только рост: падение
+// трафика обычно означает, что устройством просто меньше пользовались.
+//
+// В базовую линию входят дни с данными начиная с первого дня трафика устройства
+// в истории; дни, когда устройство молчало, считаются нулевыми. Дни без файлов
+// (роутер был выключен) пропускаются.
+func (a *Aggregator) DetectAnomalies(from, to string, opts AnomalyOptions) *AnomalyReport {
+	result := &AnomalyReport{
+		From:      from,
+		To:        to,
+		Window:    opts.Window,
+		Threshold: opts.Threshold,
+		Anomalies: make([]Anomaly, 0),
+	}
+
+	fromTime, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return result
+	}
+	historyFrom := fromTime.AddDate(0, 0, -opts.Window).Format("2006-01-02")
+
+	devices, dates := a.deviceDays(historyFrom, to)
+	for mac, days := range devices {
+		firstSeen := ""
+		for _, date := range dates {
+			if days[date] != nil {
+				firstSeen = date
+				break
+			}
+		}
+
+		for i, date := range dates {
+			if date < from || days[date] == nil {
+				continue
+			}
+			dateTime, _ := time.Parse("2006-01-02", date)
+			windowFrom := max(dateTime.AddDate(0, 0, -opts.Window).Format("2006-01-02"), firstSeen)
+
+			var history []*deviceDay
+			for _, prev := range dates[:i] {
+				if prev < windowFrom {
+					continue
+				}
+				day := days[prev]
+				if day == nil {
+					day = &deviceDay{}
+				}
+				history = append(history, day)
+			}
+			if len(history) < anomalyMinHistory {
+				continue
+			}
+
+			for _, anomaly := range detectDeviceAnomalies(days[date], history, opts.Threshold) {
+				anomaly.Date = date
+				anomaly.MAC = mac
+				anomaly.FriendlyName = a.FriendlyName(mac)
+				anomaly.HistoryDays = len(history)
+				result.Anomalies = append(result.Anomalies, anomaly)
+			}
+		}
+	}
+
+	sort.Slice(result.Anomalies, func(i, j int) bool {
+		ai, aj := result.Anomalies[i], result.Anomalies[j]
+		if ai.Date != aj.Date {
+			return ai.Date < aj.Date
+		}
+		if ai.Score != aj.Score {
+			return ai.Score > aj.Score
+		}
+		if ai.MAC != aj.MAC {
+			return ai.MAC < aj.MAC
+		}
+		return ai.Metric < aj.Metric
+	})
+
+	return result
+}
+
+// deviceDays собирает дневные показатели устройств за [from, to]
+// и отсортированный список дней, за которые есть данные
+func (a *Aggregator) deviceDays(from, to string) (map[string]map[string]*deviceDay, []string) {
+	devices := make(map[string]map[string]*deviceDay)
+	var dates []string
+
+	for date, entries := range a.daysInRange(from, to) {
+		dates = append(dates, date)
+		for _, data := range datasets(entries) {
+			for _, row := range data.Data {
+				if len(row) < 11 {
+					continue
+				}
+
+				mac := strings.ToLower(row[3].(string))
+				if devices[mac] == nil {
+					devices[mac] = make(map[string]*deviceDay)
+				}
+				day, exists := devices[mac][date]
+				if !exists {
+					day = &deviceDay{ports: make(map[string]uint64)}
+					devices[mac][date] = day
+				}
+
+				rx, tx := row[6].(uint64), row[8].(uint64)
+				day.downloaded += rx
+				day.uploaded += tx
+				day.connections += row[5].(uint64)
+				day.ports[fmt.Sprintf("%s/%d", row[1].(string), row[2].(uint16))] += rx + tx
+			}
+		}
+	}
+
+	sort.Strings(dates)
+	return devices, dates
+}
+
+// detectDeviceAnomalies сравнивает день с историей устройства
+func detectDeviceAnomalies(day *deviceDay, history []*deviceDay, threshold float64) []Anomaly {
+	var result []Anomaly
+
+	metrics := []struct {
+		name  string
+		value func(*deviceDay) uint64
+		floor float64
+	}{
+		{AnomalyDownloaded, func(d *deviceDay) uint64 { return d.downloaded }, anomalyMinBytes},
+		{AnomalyUploaded, func(d *deviceDay) uint64 { return d.uploaded }, anomalyMinBytes},
+		{AnomalyConnections, func(d *deviceDay) uint64 { return d.connections }, anomalyMinConns},
+	}
+	for _, metric := range metrics {
+		values := make([]float64, len(history))
+		for i, prev := range history {
+			values[i] = float64(metric.value(prev))
+		}
+		med := median(values)
+		mad := medianAbsDeviation(values, med)
+
+		value := metric.value(day)
+		deviation := float64(value) - med
+		if deviation < metric.floor {
+			continue
+		}
+
+		// Постоянный трафик даёт MAD = 0 - разброс не меньше 10% медианы и порога
+		scale := max(mad*madScale, med*0.1, metric.floor)
+		if score := deviation / scale; score >= threshold {
+			result = append(result, Anomaly{Metric: metric.name, Value: value, Median: med, MAD: mad, Score: score})
+		}
+	}
+
+	// Порты, которых не было во всём окне
+	seen := make(map[string]bool)
+	for _, prev := range history {
+		for port := range prev.ports {
+			seen[port] = true
+		}
+	}
+	var ports []string
+	var portTraffic uint64
+	for port, traffic := range day.ports {
+		if !seen[port] && traffic >= anomalyMinPort {
+			ports = append(ports, port)
+			portTraffic += traffic
+		}
+	}
+	if len(ports) > 0 {
+		sort.Strings(ports)
+		result = append(result, Anomaly{
+			Metric: AnomalyNewPorts,
+			Value:  portTraffic,
+			Ports:  ports,
+		})
+	}
+
+	return result
+}
+
+// median возвращает медиану, values не меняется
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// medianAbsDeviation возвращает медиану абсолютных отклонений от med (MAD)
+func medianAbsDeviation(values []float64, med float64) float64 {
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value - med)
+	}
+	return median(deviations)
+}
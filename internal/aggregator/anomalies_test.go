@@ -0,0 +1,107 @@
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+)
+
+// cameraDays - 14 дней ровного трафика камеры и телефона, затем всплеск
+func cameraDays(spike map[string][][]interface{}) map[string][][]interface{} {
+	days := make(map[string][][]interface{})
+	for day := 1; day <= 14; day++ {
+		jitter := uint64(day%3) << 20
+		days[fmt.Sprintf("202401%02d", day)] = [][]interface{}{
+			row("TCP", 443, "ca:ca:ca:ca:ca:ca", 5<<20, 50<<20+jitter),
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 500<<20+jitter*10, 20<<20),
+		}
+	}
+	for day, rows := range spike {
+		days[day] = rows
+	}
+	return days
+}
+
+func TestDetectAnomalies_UploadSpike(t *testing.T) {
+	agg := newTestAggregator(cameraDays(map[string][][]interface{}{
+		"20240115": {
+			row("TCP", 443, "ca:ca:ca:ca:ca:ca", 5<<20, 4<<30),
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 510<<20, 20<<20),
+		},
+	}))
+
+	report := agg.DetectAnomalies("2024-01-15", "2024-01-15", AnomalyOptions{Window: DefaultAnomalyWindow, Threshold: DefaultAnomalyThreshold})
+
+	if len(report.Anomalies) != 1 {
+		t.Fatalf("Expected one anomaly, got %+v", report.Anomalies)
+	}
+	anomaly := report.Anomalies[0]
+	if anomaly.MAC != "ca:ca:ca:ca:ca:ca" || anomaly.Metric != AnomalyUploaded || anomaly.Value != 4<<30 {
+		t.Errorf("Unexpected anomaly: %+v", anomaly)
+	}
+	if anomaly.Median != 51<<20 || anomaly.HistoryDays != 14 || anomaly.Score < DefaultAnomalyThreshold {
+		t.Errorf("Unexpected baseline: %+v", anomaly)
+	}
+}
+
+func TestDetectAnomalies_NewPortsAndHistory(t *testing.T) {
+	telnet := row("TCP", 23, "ca:ca:ca:ca:ca:ca", 0, 2<<20)
+	telnet[5] = uint64(1)
+	agg := newTestAggregator(cameraDays(map[string][][]interface{}{
+		"20240115": {
+			row("TCP", 443, "ca:ca:ca:ca:ca:ca", 5<<20, 50<<20),
+			telnet,
+			// Новое устройство без истории не проверяется
+			row("TCP", 443, "bb:bb:bb:bb:bb:bb", 10<<30, 0),
+		},
+	}))
+
+	report := agg.DetectAnomalies("2024-01-01", "2024-01-15", AnomalyOptions{Window: 28, Threshold: DefaultAnomalyThreshold})
+
+	if len(report.Anomalies) != 1 {
+		t.Fatalf("Expected one anomaly, got %+v", report.Anomalies)
+	}
+	anomaly := report.Anomalies[0]
+	if anomaly.Metric != AnomalyNewPorts || len(anomaly.Ports) != 1 || anomaly.Ports[0] != "TCP/23" {
+		t.Errorf("Unexpected anomaly: %+v", anomaly)
+	}
+}
+
+func TestMedianAbsDeviation(t *testing.T) {
+	values := []float64{1, 1, 2, 2, 4, 6, 9}
+	med := median(values)
+	if med != 2 || medianAbsDeviation(values, med) != 1 {
+		t.Errorf("Unexpected median %v or MAD %v", med, medianAbsDeviation(values, med))
+	}
+	if median([]float64{4, 1, 3, 2}) != 2.5 {
+		t.Error("Unexpected median of an even number of values")
+	}
+}
+
+func TestDetectAnomalies_QuietDaysInBaseline(t *testing.T) {
+	days := cameraDays(nil)
+	// Планшет включают раз в несколько дней: 4 дня с трафиком из 14
+	for _, day := range []string{"20240101", "20240105", "20240109", "20240113"} {
+		days[day] = append(days[day], row("TCP", 443, "dd:dd:dd:dd:dd:dd", 100<<20, 0))
+	}
+	// Устройство появилось 10-го: в базовой линии только 5 дней
+	for day := 10; day <= 14; day++ {
+		date := fmt.Sprintf("202401%02d", day)
+		days[date] = append(days[date], row("TCP", 443, "ee:ee:ee:ee:ee:ee", 1<<20, 0))
+	}
+	days["20240115"] = [][]interface{}{
+		row("TCP", 443, "dd:dd:dd:dd:dd:dd", 300<<20, 0),
+		row("TCP", 443, "ee:ee:ee:ee:ee:ee", 10<<30, 0),
+	}
+	agg := newTestAggregator(days)
+
+	report := agg.DetectAnomalies("2024-01-15", "2024-01-15", AnomalyOptions{Window: DefaultAnomalyWindow, Threshold: DefaultAnomalyThreshold})
+
+	if len(report.Anomalies) != 1 {
+		t.Fatalf("Expected one anomaly, got %+v", report.Anomalies)
+	}
+	// Дни без трафика входят в базовую линию нулями
+	anomaly := report.Anomalies[0]
+	if anomaly.MAC != "dd:dd:dd:dd:dd:dd" || anomaly.Metric != AnomalyDownloaded || anomaly.HistoryDays != 14 || anomaly.Median != 0 {
+		t.Errorf("Unexpected anomaly: %+v", anomaly)
+	}
+}
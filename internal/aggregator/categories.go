@@ -0,0 +1,125 @@
+package aggregator
+
+import (
+	"sort"
+	"strings"
+
+	"nlbw-ui/internal/services"
+)
+
+// CategoryStats - трафик категории приложений (web, vpn, gaming, ...)
+type CategoryStats struct {
+	Category    string           `json:"category"`
+	Downloaded  uint64           `json:"downloaded"`
+	Uploaded    uint64           `json:"uploaded"`
+	RxPackets   uint64           `json:"rx_packets"`
+	TxPackets   uint64           `json:"tx_packets"`
+	Connections uint64           `json:"connections"`
+	Share       float64          `json:"share"`    // доля от всего трафика, 0..1
+	Services    []string         `json:"services"` // известные сервисы категории
+	Devices     []ProtocolDevice `json:"devices"`  // по убыванию трафика
+}
+
+// Classifier возвращает классификатор с правилами из текущего конфига
+func (a *Aggregator) Classifier() *services.Classifier {
+	// Правила проверены при загрузке конфига
+	rules, _ := a.config.Load().CategoryRules()
+	return services.NewClassifier(rules)
+}
+
+// GetCategories возвращает трафик по категориям приложений за период.
+// Если macs не пуст, учитываются только эти устройства.
+func (a *Aggregator) GetCategories(from, to string, macs []string) []CategoryStats {
+	classifier := a.Classifier()
+
+	macSet := make(map[string]bool)
+	for _, mac := range macs {
+		macSet[strings.ToLower(mac)] = true
+	}
+
+	categories := make(map[string]*CategoryStats)
+	serviceSets := make(map[string]map[string]bool)
+	deviceMap := make(map[string]map[string]*ProtocolDevice)
+	var total uint64
+
+	for _, entries := range a.daysInRange(from, to) {
+		for _, data := range datasets(entries) {
+			for _, row := range data.Data {
+				if len(row) < 11 {
+					continue
+				}
+
+				mac := strings.ToLower(row[3].(string))
+				if len(macSet) > 0 && !macSet[mac] {
+					continue
+				}
+
+				proto := row[1].(string)
+				port := row[2].(uint16)
+				layer7, _ := row[10].(string)
+				category := classifier.Classify(proto, port, layer7)
+
+				if _, exists := categories[category]; !exists {
+					categories[category] = &CategoryStats{Category: category}
+					serviceSets[category] = make(map[string]bool)
+					deviceMap[category] = make(map[string]*ProtocolDevice)
+				}
+
+				cs := categories[category]
+				cs.Downloaded += row[6].(uint64)
+				cs.Uploaded += row[8].(uint64)
+				cs.RxPackets += row[7].(uint64)
+				cs.TxPackets += row[9].(uint64)
+				cs.Connections += row[5].(uint64)
+				total += row[6].(uint64) + row[8].(uint64)
+
+				if layer7 == "" {
+					layer7 = services.Name(proto, port)
+				}
+				if layer7 != "" {
+					serviceSets[category][layer7] = true
+				}
+
+				devices := deviceMap[category]
+				if _, exists := devices[mac]; !exists {
+					devices[mac] = &ProtocolDevice{
+						MAC:          mac,
+						FriendlyName: a.FriendlyName(mac),
+					}
+				}
+				devices[mac].Downloaded += row[6].(uint64)
+				devices[mac].Uploaded += row[8].(uint64)
+			}
+		}
+	}
+
+	result := make([]CategoryStats, 0, len(categories))
+	for category, cs := range categories {
+		cs.Share = share(cs.Downloaded+cs.Uploaded, total)
+
+		cs.Services = make([]string, 0, len(serviceSets[category]))
+		for name := range serviceSets[category] {
+			cs.Services = append(cs.Services, name)
+		}
+		sort.Strings(cs.Services)
+
+		cs.Devices = make([]ProtocolDevice, 0, len(deviceMap[category]))
+		for _, device := range deviceMap[category] {
+			cs.Devices = append(cs.Devices, *device)
+		}
+		sortProtocolDevices(cs.Devices)
+
+		result = append(result, *cs)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		ti := result[i].Downloaded + result[i].Uploaded
+		tj := result[j].Downloaded + result[j].Uploaded
+		if ti != tj {
+			return ti > tj
+		}
+		return result[i].Category < result[j].Category
+	})
+
+	return result
+}
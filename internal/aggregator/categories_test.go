@@ -0,0 +1,42 @@
+package aggregator
+
+import (
+	"testing"
+
+	"nlbw-ui/internal/config"
+)
+
+func TestGetCategories(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 600, 100),
+			row("UDP", 53, "aa:aa:aa:aa:aa:aa", 50, 50),
+			row("UDP", 40000, "bb:bb:bb:bb:bb:bb", 150, 50),
+			row("TCP", 12345, "bb:bb:bb:bb:bb:bb", 0, 0),
+		},
+	})
+	agg.SetConfig(&config.Config{Categories: []config.CategoryRule{
+		{Category: "gaming", Proto: "udp", Ports: []string{"40000-40010"}},
+	}})
+
+	categories := agg.GetCategories("2024-01-01", "2024-01-01", nil)
+	if len(categories) != 4 {
+		t.Fatalf("Expected 4 categories, got %+v", categories)
+	}
+
+	web := categories[0]
+	if web.Category != "web" || web.Downloaded != 600 || web.Share != 0.7 || len(web.Services) != 1 || web.Services[0] != "HTTPS" {
+		t.Errorf("Unexpected web category: %+v", web)
+	}
+	if categories[1].Category != "gaming" || categories[1].Devices[0].MAC != "bb:bb:bb:bb:bb:bb" {
+		t.Errorf("Expected gaming from config rule, got %+v", categories[1])
+	}
+	if categories[2].Category != "dns" || categories[3].Category != "other" {
+		t.Errorf("Unexpected order: %+v", categories)
+	}
+
+	filtered := agg.GetCategories("2024-01-01", "2024-01-01", []string{"AA:AA:AA:AA:AA:AA"})
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 categories for one device, got %+v", filtered)
+	}
+}
@@ -0,0 +1,263 @@
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Period - один из сравниваемых периодов
+type Period struct {
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	Days       int     `json:"days"`      // длина периода в календарных днях
+	DataDays   int     `json:"data_days"` // дни, за которые есть данные
+	Downloaded uint64  `json:"downloaded"`
+	Uploaded   uint64  `json:"uploaded"`
+	PerDay     float64 `json:"per_day"` // среднее (download+upload) за календарный день
+}
+
+// CompareItem - изменение трафика устройства или протокола между периодами.
+// Delta = B - A. Проценты не заполняются, если в периоде A трафика не было.
+type CompareItem struct {
+	MAC          string `json:"mac,omitempty"`
+	FriendlyName string `json:"friendly_name,omitempty"`
+	Protocol     string `json:"protocol,omitempty"`
+	Port         uint16 `json:"port,omitempty"`
+
+	A            uint64   `json:"a"`
+	B            uint64   `json:"b"`
+	Delta        int64    `json:"delta"`
+	DeltaPercent *float64 `json:"delta_percent"`
+
+	// Средние за день - для периодов разной длины
+	PerDayA            float64  `json:"per_day_a"`
+	PerDayB            float64  `json:"per_day_b"`
+	PerDayDelta        float64  `json:"per_day_delta"`
+	PerDayDeltaPercent *float64 `json:"per_day_delta_percent"`
+}
+
+// Comparison - ответ /api/compare
+type Comparison struct {
+	A         Period        `json:"a"`
+	B         Period        `json:"b"`
+	Total     CompareItem   `json:"total"`
+	Devices   []CompareItem `json:"devices"`   // по убыванию |delta|
+	Protocols []CompareItem `json:"protocols"` // по убыванию |delta|
+	OnlyInA   []string      `json:"only_in_a"` // MAC устройств, которых нет в периоде B
+	OnlyInB   []string      `json:"only_in_b"` // MAC устройств, которых нет в периоде A
+}
+
+// Compare сравнивает два периода: общий трафик, устройства и протоколы.
+// Если macs не пуст, учитываются только эти устройства.
+func (a *Aggregator) Compare(aFrom, aTo, bFrom, bTo string, macs []string) *Comparison {
+	summaryA := a.GetSummary(aFrom, aTo)
+	summaryB := a.GetSummary(bFrom, bTo)
+	filterSummary(summaryA, macs)
+	filterSummary(summaryB, macs)
+
+	result := &Comparison{
+		A:       newPeriod(summaryA),
+		B:       newPeriod(summaryB),
+		OnlyInA: make([]string, 0),
+		OnlyInB: make([]string, 0),
+	}
+	daysA, daysB := result.A.Days, result.B.Days
+
+	result.Total = newCompareItem(
+		summaryA.TotalDownloaded+summaryA.TotalUploaded,
+		summaryB.TotalDownloaded+summaryB.TotalUploaded,
+		daysA, daysB,
+	)
+
+	// Устройства
+	devices := make(map[string]*CompareItem)
+	for mac, device := range summaryA.Devices {
+		item := newCompareItem(device.Downloaded+device.Uploaded, 0, daysA, daysB)
+		item.MAC, item.FriendlyName = mac, device.FriendlyName
+		devices[mac] = &item
+	}
+	for mac, device := range summaryB.Devices {
+		var totalA uint64
+		if _, ok := summaryA.Devices[mac]; ok {
+			totalA = devices[mac].A
+		} else {
+			result.OnlyInB = append(result.OnlyInB, mac)
+		}
+		item := newCompareItem(totalA, device.Downloaded+device.Uploaded, daysA, daysB)
+		item.MAC, item.FriendlyName = mac, device.FriendlyName
+		devices[mac] = &item
+	}
+	for mac := range summaryA.Devices {
+		if _, ok := summaryB.Devices[mac]; !ok {
+			result.OnlyInA = append(result.OnlyInA, mac)
+		}
+	}
+	sort.Strings(result.OnlyInA)
+	sort.Strings(result.OnlyInB)
+	result.Devices = sortedItems(devices)
+
+	// Протоколы всей сети (с учётом фильтра устройств)
+	protocolsA := a.protocolTotals(aFrom, aTo, summaryA)
+	protocolsB := a.protocolTotals(bFrom, bTo, summaryB)
+	protocols := make(map[string]*CompareItem)
+	for key, stats := range protocolsA {
+		item := newCompareItem(stats.Downloaded+stats.Uploaded, 0, daysA, daysB)
+		item.Protocol, item.Port = stats.Protocol, stats.Port
+		protocols[key] = &item
+	}
+	for key, stats := range protocolsB {
+		var totalA uint64
+		if existing, ok := protocols[key]; ok {
+			totalA = existing.A
+		}
+		item := newCompareItem(totalA, stats.Downloaded+stats.Uploaded, daysA, daysB)
+		item.Protocol, item.Port = stats.Protocol, stats.Port
+		protocols[key] = &item
+	}
+	result.Protocols = sortedItems(protocols)
+
+	return result
+}
+
+// protocolTotals суммирует протоколы всех устройств summary за период
+// одним проходом по дням
+func (a *Aggregator) protocolTotals(from, to string, summary *Summary) map[string]*ProtocolStats {
+	macSet := make(map[string]bool, len(summary.Devices))
+	for mac := range summary.Devices {
+		macSet[strings.ToLower(mac)] = true
+	}
+
+	totals := make(map[string]*ProtocolStats)
+	for _, entries := range a.daysInRange(from, to) {
+		for _, data := range datasets(entries) {
+			for _, row := range data.Data {
+				if len(row) < 11 || !macSet[strings.ToLower(row[3].(string))] {
+					continue
+				}
+
+				proto := row[1].(string)
+				port := row[2].(uint16)
+				key := fmt.Sprintf("%s:%d", proto, port)
+
+				if _, exists := totals[key]; !exists {
+					totals[key] = &ProtocolStats{Protocol: proto, Port: port}
+				}
+				total := totals[key]
+				total.Downloaded += row[6].(uint64)
+				total.Uploaded += row[8].(uint64)
+				total.RxPackets += row[7].(uint64)
+				total.TxPackets += row[9].(uint64)
+				total.Connections += row[5].(uint64)
+			}
+		}
+	}
+	return totals
+}
+
+// filterSummary оставляет в summary только устройства из macs и
+// пересчитывает итоги. Пустой macs - без фильтра.
+func filterSummary(summary *Summary, macs []string) {
+	if len(macs) == 0 {
+		return
+	}
+
+	macSet := make(map[string]bool)
+	for _, mac := range macs {
+		macSet[strings.ToLower(mac)] = true
+	}
+
+	summary.TotalDownloaded, summary.TotalUploaded = 0, 0
+	for mac, device := range summary.Devices {
+		if !macSet[strings.ToLower(mac)] {
+			delete(summary.Devices, mac)
+			continue
+		}
+		summary.TotalDownloaded += device.Downloaded
+		summary.TotalUploaded += device.Uploaded
+	}
+}
+
+func newPeriod(summary *Summary) Period {
+	period := Period{
+		From:       summary.From,
+		To:         summary.To,
+		Days:       periodDays(summary.From, summary.To),
+		DataDays:   len(summary.Days),
+		Downloaded: summary.TotalDownloaded,
+		Uploaded:   summary.TotalUploaded,
+	}
+	period.PerDay = perDay(period.Downloaded+period.Uploaded, period.Days)
+	return period
+}
+
+func newCompareItem(totalA, totalB uint64, daysA, daysB int) CompareItem {
+	item := CompareItem{
+		A:       totalA,
+		B:       totalB,
+		Delta:   int64(totalB) - int64(totalA),
+		PerDayA: perDay(totalA, daysA),
+		PerDayB: perDay(totalB, daysB),
+	}
+	item.PerDayDelta = item.PerDayB - item.PerDayA
+	item.DeltaPercent = percentChange(float64(totalA), float64(totalB))
+	item.PerDayDeltaPercent = percentChange(item.PerDayA, item.PerDayB)
+	return item
+}
+
+// periodDays возвращает число календарных дней в [from, to]
+func periodDays(from, to string) int {
+	fromTime, errFrom := time.Parse("2006-01-02", from)
+	toTime, errTo := time.Parse("2006-01-02", to)
+	if errFrom != nil || errTo != nil || toTime.Before(fromTime) {
+		return 0
+	}
+	return int(toTime.Sub(fromTime).Hours()/24) + 1
+}
+
+func perDay(total uint64, days int) float64 {
+	if days == 0 {
+		return 0
+	}
+	return float64(total) / float64(days)
+}
+
+// percentChange возвращает изменение b относительно a в процентах, nil при a == 0
+func percentChange(a, b float64) *float64 {
+	if a == 0 {
+		return nil
+	}
+	percent := (b - a) / a * 100
+	return &percent
+}
+
+// sortedItems сортирует изменения по убыванию модуля delta
+func sortedItems(items map[string]*CompareItem) []CompareItem {
+	result := make([]CompareItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, *item)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		di, dj := abs64(result[i].Delta), abs64(result[j].Delta)
+		if di != dj {
+			return di > dj
+		}
+		if result[i].MAC != result[j].MAC {
+			return result[i].MAC < result[j].MAC
+		}
+		if result[i].Protocol != result[j].Protocol {
+			return result[i].Protocol < result[j].Protocol
+		}
+		return result[i].Port < result[j].Port
+	})
+	return result
+}
+
+func abs64(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
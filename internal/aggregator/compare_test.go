@@ -0,0 +1,138 @@
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/config"
+	"nlbw-ui/internal/converter"
+)
+
+// row собирает строку TrafficData в формате converter
+func row(proto string, port uint16, mac string, rx, tx uint64) []interface{} {
+	return []interface{}{4, proto, port, mac, "192.168.1.10", uint64(1), rx, uint64(1), tx, uint64(1), nil}
+}
+
+func newTestAggregator(days map[string][][]interface{}) *Aggregator {
+	c := cache.New()
+	for day, rows := range days {
+		c.Set(cache.Key("main", "/data/"+day+".db.gz"), &converter.TrafficData{Data: rows})
+	}
+	return New(c, &config.Config{})
+}
+
+func TestCompare(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		// Период A: 1 день
+		"20240101": {
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 100, 0),
+			row("UDP", 53, "bb:bb:bb:bb:bb:bb", 50, 50),
+		},
+		// Период B: 2 дня
+		"20240110": {
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 150, 50),
+			row("UDP", 53, "cc:cc:cc:cc:cc:cc", 10, 0),
+		},
+		"20240111": {row("TCP", 443, "aa:aa:aa:aa:aa:aa", 100, 0)},
+	})
+
+	result := agg.Compare("2024-01-01", "2024-01-01", "2024-01-10", "2024-01-11", nil)
+
+	if result.A.Days != 1 || result.B.Days != 2 || result.B.DataDays != 2 {
+		t.Errorf("Unexpected periods: %+v %+v", result.A, result.B)
+	}
+	if result.Total.A != 200 || result.Total.B != 310 || result.Total.Delta != 110 {
+		t.Errorf("Unexpected total: %+v", result.Total)
+	}
+	if result.Total.PerDayB != 155 || *result.Total.PerDayDeltaPercent != -22.5 {
+		t.Errorf("Unexpected per-day values: %+v", result.Total)
+	}
+
+	if len(result.OnlyInA) != 1 || result.OnlyInA[0] != "bb:bb:bb:bb:bb:bb" {
+		t.Errorf("Unexpected only_in_a: %v", result.OnlyInA)
+	}
+	if len(result.OnlyInB) != 1 || result.OnlyInB[0] != "cc:cc:cc:cc:cc:cc" {
+		t.Errorf("Unexpected only_in_b: %v", result.OnlyInB)
+	}
+
+	// Наибольшее изменение - первым
+	first := result.Devices[0]
+	if first.MAC != "aa:aa:aa:aa:aa:aa" || first.Delta != 200 || *first.DeltaPercent != 200 {
+		t.Errorf("Unexpected first device: %+v", first)
+	}
+	for _, device := range result.Devices {
+		if device.MAC == "cc:cc:cc:cc:cc:cc" && device.DeltaPercent != nil {
+			t.Error("Percent must be empty for a device absent in A")
+		}
+	}
+
+	if len(result.Protocols) != 2 || result.Protocols[0].Protocol != "TCP" || result.Protocols[0].Delta != 200 {
+		t.Errorf("Unexpected protocols: %+v", result.Protocols)
+	}
+	if udp := result.Protocols[1]; udp.A != 100 || udp.B != 10 {
+		t.Errorf("Unexpected UDP: %+v", udp)
+	}
+}
+
+func TestCompare_FilterByMACs(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 100, 0),
+			row("UDP", 53, "bb:bb:bb:bb:bb:bb", 50, 50),
+		},
+		"20240102": {row("UDP", 53, "bb:bb:bb:bb:bb:bb", 10, 0)},
+	})
+
+	result := agg.Compare("2024-01-01", "2024-01-01", "2024-01-02", "2024-01-02", []string{"BB:BB:BB:BB:BB:BB"})
+
+	if len(result.Devices) != 1 || result.Total.A != 100 || result.Total.B != 10 {
+		t.Errorf("Unexpected filtered result: %+v", result.Total)
+	}
+	if len(result.Protocols) != 1 || result.Protocols[0].Protocol != "UDP" {
+		t.Errorf("Unexpected filtered protocols: %+v", result.Protocols)
+	}
+}
+
+func TestCompare_ProtocolsAcrossDevices(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 100, 10),
+			row("TCP", 443, "bb:bb:bb:bb:bb:bb", 200, 20),
+			row("UDP", 53, "cc:cc:cc:cc:cc:cc", 5, 5),
+		},
+		"20240102": {
+			row("TCP", 443, "AA:AA:AA:AA:AA:AA", 50, 0),
+			row("UDP", 53, "bb:bb:bb:bb:bb:bb", 10, 0),
+		},
+	})
+
+	result := agg.Compare("2024-01-01", "2024-01-02", "2024-01-03", "2024-01-03", []string{"aa:aa:aa:aa:aa:aa", "bb:bb:bb:bb:bb:bb"})
+
+	// Протоколы суммируются по всем выбранным устройствам и дням, cc не учитывается
+	totals := make(map[string]uint64)
+	for _, item := range result.Protocols {
+		totals[item.Protocol] = item.A
+	}
+	if totals["TCP"] != 380 || totals["UDP"] != 10 {
+		t.Errorf("Unexpected protocol totals: %v", totals)
+	}
+}
+
+func BenchmarkCompare(b *testing.B) {
+	days := make(map[string][][]interface{})
+	for day := 1; day <= 28; day++ {
+		var rows [][]interface{}
+		for device := 0; device < 50; device++ {
+			mac := fmt.Sprintf("aa:bb:cc:dd:ee:%02x", device)
+			rows = append(rows, row("TCP", 443, mac, 1000, 100), row("UDP", 53, mac, 10, 10))
+		}
+		days[fmt.Sprintf("202401%02d", day)] = rows
+	}
+	agg := newTestAggregator(days)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		agg.Compare("2024-01-01", "2024-01-14", "2024-01-15", "2024-01-28", nil)
+	}
+}
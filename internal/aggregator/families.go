@@ -0,0 +1,74 @@
+package aggregator
+
+// FamilyStats - трафик одного семейства адресов (IPv4 или IPv6)
+type FamilyStats struct {
+	Downloaded  uint64 `json:"downloaded"`
+	Uploaded    uint64 `json:"uploaded"`
+	RxPackets   uint64 `json:"rx_packets"`
+	TxPackets   uint64 `json:"tx_packets"`
+	Connections uint64 `json:"connections"`
+}
+
+// FamilySplit - разбивка трафика по IPv4/IPv6 (колонка family)
+type FamilySplit struct {
+	IPv4      FamilyStats `json:"ipv4"`
+	IPv6      FamilyStats `json:"ipv6"`
+	IPv6Share float64     `json:"ipv6_share"` // доля IPv6 в байтах, 0..1
+}
+
+// addRow добавляет строку TrafficData в разбивку
+func (f *FamilySplit) addRow(row []interface{}) {
+	stats := &f.IPv4
+	if family, _ := row[0].(int); family == 6 {
+		stats = &f.IPv6
+	}
+	stats.Downloaded += row[6].(uint64)
+	stats.Uploaded += row[8].(uint64)
+	stats.RxPackets += row[7].(uint64)
+	stats.TxPackets += row[9].(uint64)
+	stats.Connections += row[5].(uint64)
+	f.updateShare()
+}
+
+// add суммирует другую разбивку
+func (f *FamilySplit) add(other FamilySplit) {
+	for _, pair := range [][2]*FamilyStats{{&f.IPv4, &other.IPv4}, {&f.IPv6, &other.IPv6}} {
+		pair[0].Downloaded += pair[1].Downloaded
+		pair[0].Uploaded += pair[1].Uploaded
+		pair[0].RxPackets += pair[1].RxPackets
+		pair[0].TxPackets += pair[1].TxPackets
+		pair[0].Connections += pair[1].Connections
+	}
+	f.updateShare()
+}
+
+func (f *FamilySplit) updateShare() {
+	v6 := f.IPv6.Downloaded + f.IPv6.Uploaded
+	f.IPv6Share = share(v6, f.IPv4.Downloaded+f.IPv4.Uploaded+v6)
+}
+
+// FamilyPoint - точка timeseries /api/families
+type FamilyPoint struct {
+	Date  string `json:"date"`
+	End   string `json:"end"`
+	Label string `json:"label"`
+	FamilySplit
+}
+
+// GetFamilies возвращает разбивку IPv4/IPv6 за период по интервалам
+// (день, неделя, месяц, год) - для графика доли IPv6 во времени
+func (a *Aggregator) GetFamilies(from, to string, macs []string, opts TimeseriesOptions) []FamilyPoint {
+	opts.Devices = false
+	buckets := a.GetBucketedTimeseries(from, to, macs, opts)
+
+	result := make([]FamilyPoint, len(buckets))
+	for i, bucket := range buckets {
+		result[i] = FamilyPoint{
+			Date:        bucket.Date,
+			End:         bucket.End,
+			Label:       bucket.Label,
+			FamilySplit: bucket.Families,
+		}
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+package aggregator
+
+import "testing"
+
+// row6 - строка IPv6
+func row6(proto string, port uint16, mac string, rx, tx uint64) []interface{} {
+	r := row(proto, port, mac, rx, tx)
+	r[0], r[4] = 6, "fd00::10"
+	return r
+}
+
+func TestFamilies(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 300, 0),
+			row6("TCP", 443, "aa:aa:aa:aa:aa:aa", 100, 0),
+			row("UDP", 53, "bb:bb:bb:bb:bb:bb", 100, 0),
+		},
+		"20240102": {row6("UDP", 443, "aa:aa:aa:aa:aa:aa", 500, 0)},
+	})
+
+	summary := agg.GetSummary("2024-01-01", "2024-01-02")
+	if summary.Families.IPv4.Downloaded != 400 || summary.Families.IPv6.Downloaded != 600 || summary.Families.IPv6Share != 0.6 {
+		t.Errorf("Unexpected network families: %+v", summary.Families)
+	}
+	device := summary.Devices["aa:aa:aa:aa:aa:aa"].Families
+	if device.IPv4.Downloaded != 300 || device.IPv6.Downloaded != 600 || device.IPv6.Connections != 2 {
+		t.Errorf("Unexpected device families: %+v", device)
+	}
+
+	points := agg.GetFamilies("2024-01-01", "2024-01-02", nil, TimeseriesOptions{Bucket: BucketDay})
+	if len(points) != 2 || points[0].IPv6Share != 0.2 || points[1].IPv6Share != 1 {
+		t.Errorf("Unexpected family timeseries: %+v", points)
+	}
+
+	filtered := agg.GetFamilies("2024-01-01", "2024-01-02", []string{"bb:bb:bb:bb:bb:bb"}, TimeseriesOptions{Bucket: BucketMonth})
+	if len(filtered) != 1 || filtered[0].IPv4.Downloaded != 100 || filtered[0].IPv6Share != 0 {
+		t.Errorf("Unexpected filtered families: %+v", filtered)
+	}
+}
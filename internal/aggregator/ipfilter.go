@@ -0,0 +1,86 @@
+package aggregator
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPFilter отбирает строки трафика по колонке ip: отдельные адреса и подсети
+// (IPv4 и IPv6). Строка проходит, если совпала хотя бы с одним условием.
+type IPFilter struct {
+	ips      map[string]bool
+	networks []*net.IPNet
+}
+
+// ParseIPFilter разбирает параметры ips=addr1,addr2 и cidr=net1,net2.
+// Если оба пусты, возвращает nil - без фильтра.
+func ParseIPFilter(ips, cidrs string) (*IPFilter, error) {
+	filter := &IPFilter{ips: make(map[string]bool)}
+
+	for _, value := range splitList(ips) {
+		ip := NormalizeIP(value)
+		if ip == "" {
+			return nil, fmt.Errorf("invalid ip %q", value)
+		}
+		filter.ips[ip] = true
+	}
+	for _, value := range splitList(cidrs) {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", value)
+		}
+		filter.networks = append(filter.networks, network)
+	}
+
+	if len(filter.ips) == 0 && len(filter.networks) == 0 {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// Match проверяет адрес из колонки ip
+func (f *IPFilter) Match(value string) bool {
+	if f == nil {
+		return true
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+	if f.ips[ip.String()] {
+		return true
+	}
+	for _, network := range f.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRow проверяет колонку ip строки TrafficData
+func (f *IPFilter) matchRow(row []interface{}) bool {
+	ip, _ := row[4].(string)
+	return f.Match(ip)
+}
+
+// WithIPFilter возвращает представление агрегатора, видящее только строки
+// с адресами из filter. nil - без фильтра.
+func (a *Aggregator) WithIPFilter(filter *IPFilter) *Aggregator {
+	if filter == nil {
+		return a.ForSource(a.source)
+	}
+	return a.withRowFilter(filter.matchRow)
+}
+
+// splitList разбирает список через запятую, пропуская пустые элементы
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+package aggregator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIPFilter(t *testing.T) {
+	filter, err := ParseIPFilter("192.168.1.10, FD00::1", "192.168.2.0/24,2001:db8::/32")
+	if err != nil {
+		t.Fatalf("ParseIPFilter failed: %v", err)
+	}
+
+	matches := map[string]bool{
+		"192.168.1.10":  true,
+		"192.168.1.11":  false,
+		"192.168.2.200": true,
+		"fd00::1":       true,
+		"fd00:0::1":     true,
+		"2001:db8:1::5": true,
+		"2001:db9::5":   false,
+		"":              false,
+	}
+	for ip, expected := range matches {
+		if filter.Match(ip) != expected {
+			t.Errorf("Match(%q) = %v, expected %v", ip, !expected, expected)
+		}
+	}
+
+	if filter, err := ParseIPFilter("", ""); filter != nil || err != nil {
+		t.Errorf("Expected no filter, got %v, %v", filter, err)
+	}
+	if _, err := ParseIPFilter("nope", ""); err == nil {
+		t.Error("Expected error for invalid ip")
+	}
+	if _, err := ParseIPFilter("", "192.168.2.0"); err == nil {
+		t.Error("Expected error for cidr without prefix length")
+	}
+}
+
+func TestWithIPFilter(t *testing.T) {
+	ipRow := func(mac, ip string, rx uint64) []interface{} {
+		r := row("TCP", 443, mac, rx, 0)
+		r[4] = ip
+		if strings.Contains(ip, ":") {
+			r[0] = 6
+		}
+		return r
+	}
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			ipRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 100),
+			ipRow("bb:bb:bb:bb:bb:bb", "192.168.2.20", 10),
+			ipRow("bb:bb:bb:bb:bb:bb", "fd00:2::20", 5),
+		},
+		"20240102": {ipRow("cc:cc:cc:cc:cc:cc", "192.168.2.30", 1)},
+	})
+
+	filter, _ := ParseIPFilter("", "192.168.2.0/24,fd00:2::/64")
+	guests := agg.WithIPFilter(filter)
+
+	summary := guests.GetSummary("2024-01-01", "2024-01-31")
+	if summary.TotalDownloaded != 16 || len(summary.Devices) != 2 {
+		t.Errorf("Unexpected filtered summary: %d bytes, %d devices", summary.TotalDownloaded, len(summary.Devices))
+	}
+	if summary.Devices["aa:aa:aa:aa:aa:aa"] != nil {
+		t.Error("Device outside the subnet must be filtered out")
+	}
+
+	calendar := guests.GetCalendarData(nil)
+	if len(calendar) != 2 || calendar[0].Downloaded != 15 || calendar[1].Downloaded != 1 {
+		t.Errorf("Unexpected filtered calendar: %+v", calendar)
+	}
+
+	top := guests.Top("2024-01-01", "2024-01-31", TopOptions{By: TopByTotal, Dimension: TopIP, Limit: 10})
+	if len(top.Items) != 3 || top.Total != 16 {
+		t.Errorf("Unexpected filtered top: %+v", top.Items)
+	}
+
+	// Исходный агрегатор не меняется
+	if agg.GetSummary("2024-01-01", "2024-01-31").TotalDownloaded != 116 {
+		t.Error("Filter must not affect the original aggregator")
+	}
+}
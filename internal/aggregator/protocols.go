@@ -0,0 +1,117 @@
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"nlbw-ui/internal/services"
+)
+
+// NetworkProtocolStats - трафик протокола/порта по всей сети
+type NetworkProtocolStats struct {
+	ProtocolStats
+	Devices []ProtocolDevice `json:"devices"` // по убыванию трафика
+}
+
+// ProtocolDevice - вклад устройства в трафик протокола
+type ProtocolDevice struct {
+	MAC          string `json:"mac"`
+	FriendlyName string `json:"friendly_name"`
+	Downloaded   uint64 `json:"downloaded"`
+	Uploaded     uint64 `json:"uploaded"`
+}
+
+// GetProtocols возвращает протоколы и порты всех устройств за период
+// со списком устройств для каждого. Если macs не пуст, учитываются
+// только эти устройства.
+func (a *Aggregator) GetProtocols(from, to string, macs []string) []NetworkProtocolStats {
+	macSet := make(map[string]bool)
+	for _, mac := range macs {
+		macSet[strings.ToLower(mac)] = true
+	}
+
+	protoMap := make(map[string]*NetworkProtocolStats)
+	deviceMap := make(map[string]map[string]*ProtocolDevice)
+
+	for _, entries := range a.daysInRange(from, to) {
+		for _, data := range datasets(entries) {
+			for _, row := range data.Data {
+				if len(row) < 11 {
+					continue
+				}
+
+				mac := strings.ToLower(row[3].(string))
+				if len(macSet) > 0 && !macSet[mac] {
+					continue
+				}
+
+				proto := row[1].(string)
+				port := row[2].(uint16)
+				key := fmt.Sprintf("%s:%d", proto, port)
+
+				if _, exists := protoMap[key]; !exists {
+					protoMap[key] = &NetworkProtocolStats{
+						ProtocolStats: ProtocolStats{
+							Protocol: proto,
+							Port:     port,
+							Service:  services.Name(proto, port),
+						},
+					}
+					deviceMap[key] = make(map[string]*ProtocolDevice)
+				}
+
+				ps := protoMap[key]
+				ps.Downloaded += row[6].(uint64)
+				ps.Uploaded += row[8].(uint64)
+				ps.RxPackets += row[7].(uint64)
+				ps.TxPackets += row[9].(uint64)
+				ps.Connections += row[5].(uint64)
+
+				devices := deviceMap[key]
+				if _, exists := devices[mac]; !exists {
+					devices[mac] = &ProtocolDevice{
+						MAC:          mac,
+						FriendlyName: a.FriendlyName(mac),
+					}
+				}
+				devices[mac].Downloaded += row[6].(uint64)
+				devices[mac].Uploaded += row[8].(uint64)
+			}
+		}
+	}
+
+	result := make([]NetworkProtocolStats, 0, len(protoMap))
+	for key, ps := range protoMap {
+		ps.Devices = make([]ProtocolDevice, 0, len(deviceMap[key]))
+		for _, device := range deviceMap[key] {
+			ps.Devices = append(ps.Devices, *device)
+		}
+		sortProtocolDevices(ps.Devices)
+		result = append(result, *ps)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Downloaded != result[j].Downloaded {
+			return result[i].Downloaded > result[j].Downloaded
+		}
+		if result[i].Protocol != result[j].Protocol {
+			return result[i].Protocol < result[j].Protocol
+		}
+		return result[i].Port < result[j].Port
+	})
+
+	return result
+}
+
+// sortProtocolDevices сортирует устройства по убыванию трафика
+func sortProtocolDevices(devices []ProtocolDevice) {
+	sort.Slice(devices, func(i, j int) bool {
+		ti := devices[i].Downloaded + devices[i].Uploaded
+		tj := devices[j].Downloaded + devices[j].Uploaded
+		if ti != tj {
+			return ti > tj
+		}
+		return devices[i].MAC < devices[j].MAC
+	})
+}
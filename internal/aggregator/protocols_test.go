@@ -0,0 +1,35 @@
+package aggregator
+
+import "testing"
+
+func TestGetProtocols(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 500, 100),
+			row("TCP", 443, "bb:bb:bb:bb:bb:bb", 700, 0),
+			row("UDP", 53, "aa:aa:aa:aa:aa:aa", 10, 10),
+		},
+		"20240102": {row("TCP", 443, "aa:aa:aa:aa:aa:aa", 300, 0)},
+	})
+
+	protocols := agg.GetProtocols("2024-01-01", "2024-01-31", nil)
+	if len(protocols) != 2 {
+		t.Fatalf("Expected 2 protocols, got %+v", protocols)
+	}
+
+	https := protocols[0]
+	if https.Service != "HTTPS" || https.Downloaded != 1500 || https.Uploaded != 100 || len(https.Devices) != 2 {
+		t.Errorf("Unexpected HTTPS stats: %+v", https)
+	}
+	if https.Devices[0].MAC != "aa:aa:aa:aa:aa:aa" || https.Devices[0].Downloaded != 800 {
+		t.Errorf("Unexpected device order: %+v", https.Devices)
+	}
+	if protocols[1].Service != "DNS" {
+		t.Errorf("Expected DNS, got %+v", protocols[1])
+	}
+
+	filtered := agg.GetProtocols("2024-01-01", "2024-01-31", []string{"BB:BB:BB:BB:BB:BB"})
+	if len(filtered) != 1 || filtered[0].Downloaded != 700 || len(filtered[0].Devices) != 1 {
+		t.Errorf("Unexpected filtered protocols: %+v", filtered)
+	}
+}
@@ -0,0 +1,202 @@
+package aggregator
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"nlbw-ui/internal/config"
+)
+
+// Segmenter относит записи трафика к сегментам сети из конфига
+type Segmenter struct {
+	segments []config.SegmentConfig
+	macs     map[string]string // mac -> сегмент
+	networks []segmentNetwork  // от самых узких подсетей к широким
+}
+
+type segmentNetwork struct {
+	name    string
+	network *net.IPNet
+}
+
+// NewSegmenter собирает сегменты. Некорректные подсети пропускаются -
+// конфиг проверяется при загрузке.
+func NewSegmenter(segments []config.SegmentConfig) *Segmenter {
+	s := &Segmenter{segments: segments, macs: make(map[string]string)}
+	for _, segment := range segments {
+		for _, mac := range segment.MACs {
+			s.macs[strings.ToLower(mac)] = segment.Name
+		}
+		for _, cidr := range segment.CIDRs {
+			if _, network, err := net.ParseCIDR(cidr); err == nil {
+				s.networks = append(s.networks, segmentNetwork{segment.Name, network})
+			}
+		}
+	}
+
+	// Пересекающиеся подсети: побеждает самая узкая, при равенстве - первая в конфиге
+	sort.SliceStable(s.networks, func(i, j int) bool {
+		oi, _ := s.networks[i].network.Mask.Size()
+		oj, _ := s.networks[j].network.Mask.Size()
+		return oi > oj
+	})
+	return s
+}
+
+// Segment возвращает сегмент записи: сначала по MAC, затем по подсети,
+// иначе config.SegmentOther
+func (s *Segmenter) Segment(mac, ip string) string {
+	if name, ok := s.macs[strings.ToLower(mac)]; ok {
+		return name
+	}
+	if addr := net.ParseIP(ip); addr != nil {
+		for _, n := range s.networks {
+			if n.network.Contains(addr) {
+				return n.name
+			}
+		}
+	}
+	return config.SegmentOther
+}
+
+// Known проверяет имя сегмента (включая other)
+func (s *Segmenter) Known(name string) bool {
+	if name == config.SegmentOther {
+		return true
+	}
+	for _, segment := range s.segments {
+		if segment.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Segmenter возвращает сегменты из текущего конфига
+func (a *Aggregator) Segmenter() *Segmenter {
+	return NewSegmenter(a.config.Load().Segments)
+}
+
+// WithSegments возвращает представление агрегатора, видящее только записи
+// из сегментов names. Пустой names - без фильтра.
+func (a *Aggregator) WithSegments(names []string) (*Aggregator, error) {
+	if len(names) == 0 {
+		return a.ForSource(a.source), nil
+	}
+
+	segmenter := a.Segmenter()
+	nameSet := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !segmenter.Known(name) {
+			return nil, fmt.Errorf("unknown segment %q", name)
+		}
+		nameSet[name] = true
+	}
+
+	return a.withRowFilter(func(row []interface{}) bool {
+		mac, _ := row[3].(string)
+		ip, _ := row[4].(string)
+		return nameSet[segmenter.Segment(mac, ip)]
+	}), nil
+}
+
+// SegmentStats - трафик сегмента сети за период
+type SegmentStats struct {
+	Name        string           `json:"name"`
+	CIDRs       []string         `json:"cidrs,omitempty"`
+	Downloaded  uint64           `json:"downloaded"`
+	Uploaded    uint64           `json:"uploaded"`
+	RxPackets   uint64           `json:"rx_packets"`
+	TxPackets   uint64           `json:"tx_packets"`
+	Connections uint64           `json:"connections"`
+	Share       float64          `json:"share"` // доля от всего трафика, 0..1
+	Families    FamilySplit      `json:"families"`
+	Devices     []ProtocolDevice `json:"devices"` // по убыванию трафика
+}
+
+// SegmentSummary - ответ /api/segments/summary
+type SegmentSummary struct {
+	From            string         `json:"from"`
+	To              string         `json:"to"`
+	TotalDownloaded uint64         `json:"total_downloaded"`
+	TotalUploaded   uint64         `json:"total_uploaded"`
+	Segments        []SegmentStats `json:"segments"` // в порядке конфига, other - последним
+}
+
+// GetSegmentSummary возвращает трафик по сегментам сети за период.
+// Каждая запись относится ровно к одному сегменту, поэтому сумма
+// сегментов равна трафику всей сети.
+func (a *Aggregator) GetSegmentSummary(from, to string) *SegmentSummary {
+	segmenter := a.Segmenter()
+
+	result := &SegmentSummary{From: from, To: to}
+	stats := make(map[string]*SegmentStats)
+	devices := make(map[string]map[string]*ProtocolDevice)
+	for _, segment := range segmenter.segments {
+		stats[segment.Name] = &SegmentStats{Name: segment.Name, CIDRs: segment.CIDRs}
+	}
+
+	for _, entries := range a.daysInRange(from, to) {
+		for _, data := range datasets(entries) {
+			for _, row := range data.Data {
+				if len(row) < 11 {
+					continue
+				}
+
+				mac := strings.ToLower(row[3].(string))
+				name := segmenter.Segment(mac, row[4].(string))
+				segment, exists := stats[name]
+				if !exists {
+					segment = &SegmentStats{Name: name}
+					stats[name] = segment
+				}
+
+				rx, tx := row[6].(uint64), row[8].(uint64)
+				segment.Downloaded += rx
+				segment.Uploaded += tx
+				segment.RxPackets += row[7].(uint64)
+				segment.TxPackets += row[9].(uint64)
+				segment.Connections += row[5].(uint64)
+				segment.Families.addRow(row)
+				result.TotalDownloaded += rx
+				result.TotalUploaded += tx
+
+				if devices[name] == nil {
+					devices[name] = make(map[string]*ProtocolDevice)
+				}
+				device, exists := devices[name][mac]
+				if !exists {
+					device = &ProtocolDevice{MAC: mac, FriendlyName: a.FriendlyName(mac)}
+					devices[name][mac] = device
+				}
+				device.Downloaded += rx
+				device.Uploaded += tx
+			}
+		}
+	}
+
+	order := make([]string, 0, len(stats))
+	for _, segment := range segmenter.segments {
+		order = append(order, segment.Name)
+	}
+	if _, exists := stats[config.SegmentOther]; exists {
+		order = append(order, config.SegmentOther)
+	}
+
+	total := result.TotalDownloaded + result.TotalUploaded
+	result.Segments = make([]SegmentStats, 0, len(order))
+	for _, name := range order {
+		segment := stats[name]
+		segment.Share = share(segment.Downloaded+segment.Uploaded, total)
+		segment.Devices = make([]ProtocolDevice, 0, len(devices[name]))
+		for _, device := range devices[name] {
+			segment.Devices = append(segment.Devices, *device)
+		}
+		sortProtocolDevices(segment.Devices)
+		result.Segments = append(result.Segments, *segment)
+	}
+
+	return result
+}
@@ -0,0 +1,91 @@
+package aggregator
+
+import (
+	"testing"
+
+	"nlbw-ui/internal/config"
+)
+
+func segmentsAggregator() *Aggregator {
+	ipRow := func(mac, ip string, rx uint64) []interface{} {
+		r := row("TCP", 443, mac, rx, 0)
+		r[4] = ip
+		return r
+	}
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			ipRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 100),
+			ipRow("bb:bb:bb:bb:bb:bb", "192.168.2.20", 10),
+			ipRow("cc:cc:cc:cc:cc:cc", "192.168.1.30", 5), // в iot по MAC
+			ipRow("dd:dd:dd:dd:dd:dd", "10.0.0.1", 1),
+		},
+		"20240102": {ipRow("bb:bb:bb:bb:bb:bb", "192.168.2.20", 20)},
+	})
+	agg.SetConfig(&config.Config{Segments: []config.SegmentConfig{
+		{Name: "lan", CIDRs: []string{"192.168.0.0/16"}},
+		{Name: "guest", CIDRs: []string{"192.168.2.0/24"}},
+		{Name: "iot", MACs: []string{"CC:CC:CC:CC:CC:CC"}},
+	}})
+	return agg
+}
+
+func TestSegmenter(t *testing.T) {
+	segmenter := segmentsAggregator().Segmenter()
+
+	cases := map[[2]string]string{
+		{"aa:aa:aa:aa:aa:aa", "192.168.1.10"}: "lan",
+		{"aa:aa:aa:aa:aa:aa", "192.168.2.10"}: "guest", // самая узкая подсеть
+		{"cc:cc:cc:cc:cc:cc", "192.168.2.10"}: "iot",   // MAC важнее подсети
+		{"aa:aa:aa:aa:aa:aa", "fd00::1"}:      config.SegmentOther,
+	}
+	for record, expected := range cases {
+		if segment := segmenter.Segment(record[0], record[1]); segment != expected {
+			t.Errorf("Segment(%s, %s) = %s, expected %s", record[0], record[1], segment, expected)
+		}
+	}
+}
+
+func TestGetSegmentSummary(t *testing.T) {
+	summary := segmentsAggregator().GetSegmentSummary("2024-01-01", "2024-01-31")
+
+	if len(summary.Segments) != 4 {
+		t.Fatalf("Expected lan, guest, iot and other, got %+v", summary.Segments)
+	}
+	expected := []struct {
+		name       string
+		downloaded uint64
+	}{{"lan", 100}, {"guest", 30}, {"iot", 5}, {config.SegmentOther, 1}}
+	var sum uint64
+	for i, e := range expected {
+		segment := summary.Segments[i]
+		if segment.Name != e.name || segment.Downloaded != e.downloaded {
+			t.Errorf("Unexpected segment %d: %+v", i, segment)
+		}
+		sum += segment.Downloaded
+	}
+	if sum != summary.TotalDownloaded {
+		t.Errorf("Segments must add up to the total: %d != %d", sum, summary.TotalDownloaded)
+	}
+
+	guest := summary.Segments[1]
+	if len(guest.Devices) != 1 || guest.Devices[0].MAC != "bb:bb:bb:bb:bb:bb" || guest.CIDRs[0] != "192.168.2.0/24" {
+		t.Errorf("Unexpected guest segment: %+v", guest)
+	}
+}
+
+func TestWithSegments(t *testing.T) {
+	agg := segmentsAggregator()
+
+	guest, err := agg.WithSegments([]string{"guest", "iot"})
+	if err != nil {
+		t.Fatalf("WithSegments failed: %v", err)
+	}
+	days := guest.GetTimeseries("2024-01-01", "2024-01-31", nil)
+	if len(days) != 2 || days[0].Downloaded != 15 || days[1].Downloaded != 20 {
+		t.Errorf("Unexpected filtered timeseries: %+v", days)
+	}
+
+	if _, err := agg.WithSegments([]string{"dmz"}); err == nil {
+		t.Error("Expected error for unknown segment")
+	}
+}
@@ -0,0 +1,188 @@
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Размеры интервалов timeseries
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+	BucketYear  = "year"
+)
+
+// TimeseriesOptions - параметры GetBucketedTimeseries
+type TimeseriesOptions struct {
+	Bucket    string       // day, week, month или year
+	WeekStart time.Weekday // первый день недели для bucket=week (ISO - понедельник)
+	Devices   bool         // включать трафик по устройствам
+}
+
+// BucketStats - трафик за интервал timeseries. Поля date/downloaded/uploaded/devices
+// совпадают с DayStats, поэтому bucket=day читается как прежний ответ.
+type BucketStats struct {
+	Date       string                  `json:"date"`  // первый день интервала
+	End        string                  `json:"end"`   // последний день интервала
+	Label      string                  `json:"label"` // 2024-01-05, 2024-W01, 2024-01, 2024
+	Days       int                     `json:"days"`  // дни с файлами в интервале
+	Downloaded uint64                  `json:"downloaded"`
+	Uploaded   uint64                  `json:"uploaded"`
+	Families   FamilySplit             `json:"families"`
+	Devices    map[string]*DeviceStats `json:"devices,omitempty"`
+}
+
+// ParseBucket проверяет размер интервала, пустая строка - день
+func ParseBucket(value string) (string, error) {
+	switch value {
+	case "":
+		return BucketDay, nil
+	case BucketDay, BucketWeek, BucketMonth, BucketYear:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid bucket %q, expected day, week, month or year", value)
+}
+
+// ParseWeekday разбирает день недели (monday, mon, ...), пустая строка - понедельник
+func ParseWeekday(value string) (time.Weekday, error) {
+	if value == "" {
+		return time.Monday, nil
+	}
+	value = strings.ToLower(value)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if value == name || value == name[:3] {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid week start %q", value)
+}
+
+// GetBucketedTimeseries возвращает трафик за [from, to], сгруппированный
+// по дням, неделям, месяцам или годам. Если macs не пуст, учитываются
+// только эти устройства.
+func (a *Aggregator) GetBucketedTimeseries(from, to string, macs []string, opts TimeseriesOptions) []BucketStats {
+	buckets := make(map[string]*BucketStats)
+
+	// Разбивка по устройствам нужна не всегда - без неё хватает сумм по строкам
+	var days []DayStats
+	if opts.Devices {
+		days = a.GetTimeseries(from, to, macs)
+	} else {
+		days = a.dayTotals(from, to, macs)
+	}
+
+	for _, day := range days {
+		date, err := time.Parse("2006-01-02", day.Date)
+		if err != nil {
+			continue
+		}
+
+		start, end, label := bucketBounds(date, opts)
+		key := start.Format("2006-01-02")
+		bucket, exists := buckets[key]
+		if !exists {
+			bucket = &BucketStats{
+				Date:  key,
+				End:   end.Format("2006-01-02"),
+				Label: label,
+			}
+			if opts.Devices {
+				bucket.Devices = make(map[string]*DeviceStats)
+			}
+			buckets[key] = bucket
+		}
+
+		bucket.Days++
+		bucket.Downloaded += day.Downloaded
+		bucket.Uploaded += day.Uploaded
+		bucket.Families.add(day.Families)
+
+		if !opts.Devices {
+			continue
+		}
+		for mac, device := range day.Devices {
+			if _, exists := bucket.Devices[mac]; !exists {
+				bucket.Devices[mac] = &DeviceStats{
+					MAC:          device.MAC,
+					FriendlyName: device.FriendlyName,
+				}
+			}
+			agg := bucket.Devices[mac]
+			agg.Downloaded += device.Downloaded
+			agg.Uploaded += device.Uploaded
+			agg.RxPackets += device.RxPackets
+			agg.TxPackets += device.TxPackets
+			agg.Connections += device.Connections
+			agg.Families.add(device.Families)
+			agg.mergeAddresses(device)
+		}
+	}
+
+	result := make([]BucketStats, 0, len(buckets))
+	for _, bucket := range buckets {
+		result = append(result, *bucket)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date < result[j].Date
+	})
+
+	return result
+}
+
+// dayTotals возвращает трафик каждого дня за [from, to] без разбивки по устройствам.
+// Если macs не пуст, учитываются только эти устройства.
+func (a *Aggregator) dayTotals(from, to string, macs []string) []DayStats {
+	macSet := make(map[string]bool)
+	for _, mac := range macs {
+		macSet[strings.ToLower(mac)] = true
+	}
+
+	result := make([]DayStats, 0)
+	for date, entries := range a.daysInRange(from, to) {
+		day := DayStats{Date: date}
+		for _, data := range datasets(entries) {
+			for _, row := range data.Data {
+				if len(row) < 11 {
+					continue
+				}
+				if len(macSet) > 0 && !macSet[strings.ToLower(row[3].(string))] {
+					continue
+				}
+				day.Downloaded += row[6].(uint64)
+				day.Uploaded += row[8].(uint64)
+				day.Families.addRow(row)
+			}
+		}
+		result = append(result, day)
+	}
+	return result
+}
+
+// bucketBounds возвращает первый и последний день интервала, содержащего date, и его подпись
+func bucketBounds(date time.Time, opts TimeseriesOptions) (time.Time, time.Time, string) {
+	switch opts.Bucket {
+	case BucketWeek:
+		offset := (int(date.Weekday()) - int(opts.WeekStart) + 7) % 7
+		start := date.AddDate(0, 0, -offset)
+		label := start.Format("2006-01-02")
+		if opts.WeekStart == time.Monday {
+			year, week := date.ISOWeek()
+			label = fmt.Sprintf("%d-W%02d", year, week)
+		}
+		return start, start.AddDate(0, 0, 6), label
+
+	case BucketMonth:
+		start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, -1), start.Format("2006-01")
+
+	case BucketYear:
+		start := time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, -1), start.Format("2006")
+	}
+
+	return date, date, date.Format("2006-01-02")
+}
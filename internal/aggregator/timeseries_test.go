@@ -0,0 +1,83 @@
+package aggregator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetBucketedTimeseries(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20231231": {row("TCP", 443, "aa:aa:aa:aa:aa:aa", 1, 0)}, // воскресенье, ISO неделя 2023-W52
+		"20240101": {row("TCP", 443, "aa:aa:aa:aa:aa:aa", 10, 1)},
+		"20240107": {row("TCP", 443, "bb:bb:bb:bb:bb:bb", 100, 0)},
+		"20240108": {row("TCP", 443, "aa:aa:aa:aa:aa:aa", 1000, 0)},
+		"20240215": {row("UDP", 53, "aa:aa:aa:aa:aa:aa", 10000, 0)},
+	})
+
+	weeks := agg.GetBucketedTimeseries("2023-12-01", "2024-12-31", nil, TimeseriesOptions{Bucket: BucketWeek, WeekStart: time.Monday})
+	if len(weeks) != 4 {
+		t.Fatalf("Expected 4 ISO weeks, got %+v", weeks)
+	}
+	if weeks[0].Label != "2023-W52" || weeks[1].Label != "2024-W01" || weeks[1].Date != "2024-01-01" || weeks[1].End != "2024-01-07" {
+		t.Errorf("Unexpected weeks: %+v", weeks[:2])
+	}
+	if weeks[1].Days != 2 || weeks[1].Downloaded != 110 || weeks[1].Uploaded != 1 || weeks[1].Devices != nil {
+		t.Errorf("Unexpected week totals: %+v", weeks[1])
+	}
+
+	// Неделя с воскресенья: 31.12 и 01.01 попадают в одну неделю
+	sundayWeeks := agg.GetBucketedTimeseries("2023-12-01", "2024-12-31", nil, TimeseriesOptions{Bucket: BucketWeek, WeekStart: time.Sunday})
+	if sundayWeeks[0].Date != "2023-12-31" || sundayWeeks[0].Days != 2 || sundayWeeks[1].Date != "2024-01-07" {
+		t.Errorf("Unexpected sunday weeks: %+v", sundayWeeks)
+	}
+
+	months := agg.GetBucketedTimeseries("2023-12-01", "2024-12-31", nil, TimeseriesOptions{Bucket: BucketMonth, Devices: true})
+	if len(months) != 3 || months[1].Label != "2024-01" || months[1].End != "2024-01-31" || months[1].Downloaded != 1110 {
+		t.Fatalf("Unexpected months: %+v", months)
+	}
+	if device := months[1].Devices["aa:aa:aa:aa:aa:aa"]; device == nil || device.Downloaded != 1010 || len(months[1].Devices) != 2 {
+		t.Errorf("Unexpected per-device series: %+v", months[1].Devices)
+	}
+
+	years := agg.GetBucketedTimeseries("2023-12-01", "2024-12-31", []string{"aa:aa:aa:aa:aa:aa"}, TimeseriesOptions{Bucket: BucketYear})
+	if len(years) != 2 || years[1].Label != "2024" || years[1].Downloaded != 11010 || years[1].Days != 4 {
+		t.Errorf("Unexpected years: %+v", years)
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	for value, want := range map[string]time.Weekday{"": time.Monday, "sunday": time.Sunday, "Sat": time.Saturday} {
+		if got, err := ParseWeekday(value); err != nil || got != want {
+			t.Errorf("ParseWeekday(%q) = %v, %v", value, got, err)
+		}
+	}
+	if _, err := ParseWeekday("funday"); err == nil {
+		t.Error("Expected error for unknown weekday")
+	}
+}
+
+func TestGetBucketedTimeseries_TotalsWithoutDevices(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			row("TCP", 443, "AA:AA:AA:AA:AA:AA", 10, 1),
+			row6("TCP", 443, "bb:bb:bb:bb:bb:bb", 20, 2),
+		},
+		"20240102": {row("UDP", 53, "bb:bb:bb:bb:bb:bb", 30, 3)},
+	})
+
+	// Суммы по строкам совпадают с суммами по устройствам, в том числе с фильтром
+	for _, macs := range [][]string{nil, {"aa:aa:aa:aa:aa:aa"}} {
+		totals := agg.GetBucketedTimeseries("2024-01-01", "2024-01-02", macs, TimeseriesOptions{Bucket: BucketDay})
+		withDevices := agg.GetBucketedTimeseries("2024-01-01", "2024-01-02", macs, TimeseriesOptions{Bucket: BucketDay, Devices: true})
+		if len(totals) != len(withDevices) {
+			t.Fatalf("Expected %d days, got %d", len(withDevices), len(totals))
+		}
+		for i := range totals {
+			withDevices[i].Devices = nil
+			if !reflect.DeepEqual(totals[i], withDevices[i]) {
+				t.Errorf("macs %v: expected %+v, got %+v", macs, withDevices[i], totals[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Метрики ранжирования /api/top
+const (
+	TopByDownloaded  = "downloaded"
+	TopByUploaded    = "uploaded"
+	TopByTotal       = "total"
+	TopByConnections = "connections"
+	TopByPackets     = "packets"
+)
+
+// Измерения ранжирования /api/top
+const (
+	TopDevice   = "device"
+	TopProtocol = "protocol"
+	TopPort     = "port"
+	TopIP       = "ip"
+)
+
+// MaxTopLimit - наибольший допустимый limit для /api/top
+const MaxTopLimit = 1000
+
+// TopOptions - параметры Top
+type TopOptions struct {
+	By        string // метрика
+	Dimension string // что ранжируется
+	Limit     int    // сколько позиций вернуть, остальное уходит в other
+}
+
+// TopItem - позиция рейтинга
+type TopItem struct {
+	Key         string  `json:"key"`
+	Label       string  `json:"label"`
+	MAC         string  `json:"mac,omitempty"`
+	Protocol    string  `json:"protocol,omitempty"`
+	Port        uint16  `json:"port,omitempty"`
+	IP          string  `json:"ip,omitempty"`
+	Downloaded  uint64  `json:"downloaded"`
+	Uploaded    uint64  `json:"uploaded"`
+	Connections uint64  `json:"connections"`
+	Packets     uint64  `json:"packets"`
+	Value       uint64  `json:"value"`           // значение выбранной метрики
+	Share       float64 `json:"share"`           // доля от total, 0..1
+	Count       int     `json:"count,omitempty"` // только для other: сколько позиций объединено
+}
+
+// TopResult - ответ /api/top
+type TopResult struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	By        string    `json:"by"`
+	Dimension string    `json:"dimension"`
+	Total     uint64    `json:"total"` // сумма метрики по всем позициям
+	Items     []TopItem `json:"items"`
+	Other     *TopItem  `json:"other"` // nil, если всё поместилось в limit
+}
+
+// ValidateTopOptions проверяет метрику, измерение и limit
+func ValidateTopOptions(opts TopOptions) error {
+	switch opts.By {
+	case TopByDownloaded, TopByUploaded, TopByTotal, TopByConnections, TopByPackets:
+	default:
+		return fmt.Errorf("invalid by %q, expected downloaded, uploaded, total, connections or packets", opts.By)
+	}
+	switch opts.Dimension {
+	case TopDevice, TopProtocol, TopPort, TopIP:
+	default:
+		return fmt.Errorf("invalid dimension %q, expected device, protocol, port or ip", opts.Dimension)
+	}
+	if opts.Limit < 1 || opts.Limit > MaxTopLimit {
+		return fmt.Errorf("limit must be between 1 and %d", MaxTopLimit)
+	}
+	return nil
+}
+
+// Top ранжирует устройства, протоколы, порты или IP за период по выбранной
+// метрике. Строки читаются напрямую из кэша, без сборки полной статистики.
+func (a *Aggregator) Top(from, to string, opts TopOptions) *TopResult {
+	items := make(map[string]*TopItem)
+
+	for _, entries := range a.daysInRange(from, to) {
+		for _, data := range datasets(entries) {
+			for _, row := range data.Data {
+				if len(row) < 11 {
+					continue
+				}
+
+				proto := row[1].(string)
+				port := row[2].(uint16)
+				mac := strings.ToLower(row[3].(string))
+				ip := row[4].(string)
+
+				var key string
+				switch opts.Dimension {
+				case TopDevice:
+					key = mac
+				case TopProtocol:
+					key = proto
+				case TopPort:
+					key = fmt.Sprintf("%s:%d", proto, port)
+				case TopIP:
+					key = ip
+				}
+
+				item, exists := items[key]
+				if !exists {
+					item = &TopItem{Key: key}
+					switch opts.Dimension {
+					case TopDevice:
+						item.MAC = mac
+						item.Label = a.FriendlyName(mac)
+					case TopProtocol:
+						item.Protocol = proto
+						item.Label = proto
+					case TopPort:
+						item.Protocol, item.Port = proto, port
+						item.Label = fmt.Sprintf("%s/%d", proto, port)
+					case TopIP:
+						item.IP = ip
+						item.Label = ip
+					}
+					items[key] = item
+				}
+
+				item.Downloaded += row[6].(uint64)
+				item.Uploaded += row[8].(uint64)
+				item.Connections += row[5].(uint64)
+				item.Packets += row[7].(uint64) + row[9].(uint64)
+			}
+		}
+	}
+
+	result := &TopResult{
+		From:      from,
+		To:        to,
+		By:        opts.By,
+		Dimension: opts.Dimension,
+		Items:     make([]TopItem, 0, min(len(items), opts.Limit)),
+	}
+
+	ranked := make([]*TopItem, 0, len(items))
+	for _, item := range items {
+		item.Value = topValue(item, opts.By)
+		result.Total += item.Value
+		ranked = append(ranked, item)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Value != ranked[j].Value {
+			return ranked[i].Value > ranked[j].Value
+		}
+		return ranked[i].Key < ranked[j].Key
+	})
+
+	for i, item := range ranked {
+		if i < opts.Limit {
+			item.Share = share(item.Value, result.Total)
+			result.Items = append(result.Items, *item)
+			continue
+		}
+
+		// Всё, что не вошло в limit, суммируется в other
+		if result.Other == nil {
+			result.Other = &TopItem{Key: "other", Label: "Other"}
+		}
+		other := result.Other
+		other.Downloaded += item.Downloaded
+		other.Uploaded += item.Uploaded
+		other.Connections += item.Connections
+		other.Packets += item.Packets
+		other.Value += item.Value
+		other.Count++
+	}
+	if result.Other != nil {
+		result.Other.Share = share(result.Other.Value, result.Total)
+	}
+
+	return result
+}
+
+func topValue(item *TopItem, by string) uint64 {
+	switch by {
+	case TopByDownloaded:
+		return item.Downloaded
+	case TopByUploaded:
+		return item.Uploaded
+	case TopByConnections:
+		return item.Connections
+	case TopByPackets:
+		return item.Packets
+	}
+	return item.Downloaded + item.Uploaded
+}
+
+func share(value, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(value) / float64(total)
+}
@@ -0,0 +1,53 @@
+package aggregator
+
+import "testing"
+
+func TestTop(t *testing.T) {
+	agg := newTestAggregator(map[string][][]interface{}{
+		"20240101": {
+			row("TCP", 443, "aa:aa:aa:aa:aa:aa", 500, 100),
+			row("UDP", 53, "aa:aa:aa:aa:aa:aa", 10, 10),
+			row("TCP", 443, "bb:bb:bb:bb:bb:bb", 200, 0),
+			row("TCP", 80, "cc:cc:cc:cc:cc:cc", 50, 0),
+		},
+		"20240102": {row("TCP", 22, "dd:dd:dd:dd:dd:dd", 30, 0)},
+		"20240301": {row("TCP", 443, "dd:dd:dd:dd:dd:dd", 9999, 0)}, // вне периода
+	})
+
+	top := agg.Top("2024-01-01", "2024-01-31", TopOptions{By: TopByTotal, Dimension: TopDevice, Limit: 2})
+	if top.Total != 900 || len(top.Items) != 2 {
+		t.Fatalf("Unexpected result: %+v", top)
+	}
+	if top.Items[0].MAC != "aa:aa:aa:aa:aa:aa" || top.Items[0].Value != 620 || top.Items[1].Value != 200 {
+		t.Errorf("Unexpected ranking: %+v", top.Items)
+	}
+	if top.Other == nil || top.Other.Count != 2 || top.Other.Value != 80 || top.Other.Downloaded != 80 {
+		t.Errorf("Unexpected other: %+v", top.Other)
+	}
+
+	ports := agg.Top("2024-01-01", "2024-01-31", TopOptions{By: TopByDownloaded, Dimension: TopPort, Limit: 10})
+	if ports.Other != nil || len(ports.Items) != 4 || ports.Items[0].Label != "TCP/443" || ports.Items[0].Value != 700 {
+		t.Errorf("Unexpected ports: %+v", ports.Items)
+	}
+
+	protocols := agg.Top("2024-01-01", "2024-01-31", TopOptions{By: TopByUploaded, Dimension: TopProtocol, Limit: 1})
+	if protocols.Items[0].Protocol != "TCP" || protocols.Items[0].Share != 100.0/110 || protocols.Other.Key != "other" {
+		t.Errorf("Unexpected protocols: %+v %+v", protocols.Items, protocols.Other)
+	}
+}
+
+func TestValidateTopOptions(t *testing.T) {
+	if err := ValidateTopOptions(TopOptions{By: TopByPackets, Dimension: TopIP, Limit: 5}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, opts := range []TopOptions{
+		{By: "bytes", Dimension: TopDevice, Limit: 5},
+		{By: TopByTotal, Dimension: "mac", Limit: 5},
+		{By: TopByTotal, Dimension: TopDevice, Limit: 0},
+		{By: TopByTotal, Dimension: TopDevice, Limit: MaxTopLimit + 1},
+	} {
+		if err := ValidateTopOptions(opts); err == nil {
+			t.Errorf("Expected error for %+v", opts)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"nlbw-ui/internal/achievements"
+	"nlbw-ui/internal/aggregator"
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/converter"
+	"nlbw-ui/internal/events"
+)
+
+const (
+	// achievementCheckDelay - пауза перед пересчётом достижений,
+	// чтобы пачка загруженных файлов вызвала один пересчёт
+	achievementCheckDelay = 2 * time.Second
+
+	// anomalyCheckDelay - то же для поиска аномалий за сегодня
+	anomalyCheckDelay = 2 * time.Second
+
+	// eventsKeepAlive - период отправки комментариев, не дающих прокси закрыть поток
+	eventsKeepAlive = 30 * time.Second
+)
+
+// FileEvent - payload для file_loaded и file_modified
+type FileEvent struct {
+	Source string `json:"source"`
+	File   string `json:"file"`
+	Date   string `json:"date"`
+}
+
+// DeviceEvent - payload для device_new
+type DeviceEvent struct {
+	MAC          string `json:"mac"`
+	FriendlyName string `json:"friendly_name"`
+	IP           string `json:"ip"`
+	Date         string `json:"date"`
+}
+
+// eventPublisher превращает изменения кэша в события для SSE клиентов
+type eventPublisher struct {
+	hub        *events.Hub
+	aggregator *aggregator.Aggregator
+	calculator *achievements.Calculator
+
+	mu        sync.Mutex
+	knownMACs map[string]bool
+
+	anomalyMu    sync.Mutex
+	anomalyTimer *time.Timer
+	anomalyDate  string          // день, за который отправлены anomalies
+	anomalies    map[string]bool // уже отправленные за anomalyDate: mac/метрика
+
+	achMu        sync.Mutex
+	achTimer     *time.Timer
+	unlocked     map[string]bool
+	haveBaseline bool
+
+	now func() time.Time
+}
+
+func newEventPublisher(c *cache.Cache, hub *events.Hub, agg *aggregator.Aggregator, calc *achievements.Calculator) *eventPublisher {
+	p := &eventPublisher{
+		hub:        hub,
+		aggregator: agg,
+		calculator: calc,
+		knownMACs:  make(map[string]bool),
+		anomalies:  make(map[string]bool),
+		unlocked:   make(map[string]bool),
+		now:        time.Now,
+	}
+
+	// Устройства из уже загруженных файлов не считаются новыми
+	for _, data := range c.GetAll() {
+		for _, row := range data.Data {
+			if len(row) > 3 {
+				if mac, ok := row[3].(string); ok {
+					p.knownMACs[mac] = true
+				}
+			}
+		}
+	}
+
+	// Базовый набор разблокированных достижений считаем в фоне
+	go p.checkAchievements()
+
+	c.OnSet(p.handleSet)
+	return p
+}
+
+// handleSet вызывается кэшем после загрузки или перезагрузки файла
+func (p *eventPublisher) handleSet(key string, data *converter.TrafficData, existed bool) {
+	source, path := cache.SplitKey(key)
+	date := p.aggregator.ExtractDateFromFilename(path)
+
+	// Без SSE клиентов события не строятся; устройства всё равно запоминаются,
+	// чтобы подключившийся позже клиент не получил их как новые
+	if !p.hub.HasSubscribers() {
+		p.publishNewDevices(date, data, false)
+		return
+	}
+
+	eventType := events.TypeFileLoaded
+	if existed {
+		eventType = events.TypeFileModified
+	}
+	p.hub.Publish(eventType, FileEvent{Source: source, File: filepath.Base(path), Date: date})
+
+	if day := p.aggregator.GetCalendarDay(date); day != nil {
+		p.hub.Publish(events.TypeDayUpdated, day)
+	}
+
+	p.publishNewDevices(date, data, true)
+	// Аномалии ищутся только за сегодня: загрузка истории и архива
+	// не должна засыпать клиентов событиями за прошедшие дни
+	if date == p.today() {
+		p.scheduleAnomalyCheck()
+	}
+	p.scheduleAchievementCheck()
+}
+
+func (p *eventPublisher) today() string {
+	return p.now().Format("2006-01-02")
+}
+
+// publishNewDevices запоминает MAC из data и, если publish, отправляет device_new для новых
+func (p *eventPublisher) publishNewDevices(date string, data *converter.TrafficData, publish bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, row := range data.Data {
+		if len(row) < 5 {
+			continue
+		}
+
+		mac, ok := row[3].(string)
+		if !ok || p.knownMACs[mac] {
+			continue
+		}
+		p.knownMACs[mac] = true
+		if !publish {
+			continue
+		}
+
+		ip, _ := row[4].(string)
+		p.hub.Publish(events.TypeDeviceNew, DeviceEvent{
+			MAC:          mac,
+			FriendlyName: p.aggregator.FriendlyName(mac),
+			IP:           ip,
+			Date:         date,
+		})
+	}
+}
+
+func (p *eventPublisher) scheduleAnomalyCheck() {
+	p.anomalyMu.Lock()
+	defer p.anomalyMu.Unlock()
+
+	if p.anomalyTimer != nil {
+		p.anomalyTimer.Stop()
+	}
+	p.anomalyTimer = time.AfterFunc(anomalyCheckDelay, p.checkAnomalies)
+}
+
+// checkAnomalies ищет аномалии за сегодня и публикует новые.
+// Файл сегодняшнего дня обновляется много раз - каждая аномалия отправляется однажды;
+// с наступлением нового дня список отправленных начинается заново.
+func (p *eventPublisher) checkAnomalies() {
+	date := p.today()
+	report := p.aggregator.DetectAnomalies(date, date, aggregator.AnomalyOptions{
+		Window:    aggregator.DefaultAnomalyWindow,
+		Threshold: aggregator.DefaultAnomalyThreshold,
+	})
+
+	p.anomalyMu.Lock()
+	defer p.anomalyMu.Unlock()
+
+	if p.anomalyDate != date {
+		p.anomalyDate = date
+		p.anomalies = make(map[string]bool)
+	}
+
+	for _, anomaly := range report.Anomalies {
+		key := anomaly.MAC + "/" + anomaly.Metric
+		if p.anomalies[key] {
+			continue
+		}
+		p.anomalies[key] = true
+		p.hub.Publish(events.TypeAnomaly, anomaly)
+	}
+}
+
+func (p *eventPublisher) scheduleAchievementCheck() {
+	p.achMu.Lock()
+	defer p.achMu.Unlock()
+
+	if p.achTimer != nil {
+		p.achTimer.Stop()
+	}
+	p.achTimer = time.AfterFunc(achievementCheckDelay, p.checkAchievements)
+}
+
+// checkAchievements пересчитывает достижения и публикует новые разблокировки.
+// Первый вызов только запоминает текущее состояние.
+func (p *eventPublisher) checkAchievements() {
+	result := p.calculator.GetNetworkAchievements()
+
+	p.achMu.Lock()
+	defer p.achMu.Unlock()
+
+	for _, status := range result.Achievements {
+		if !status.Unlocked || p.unlocked[status.Achievement.ID] {
+			continue
+		}
+		p.unlocked[status.Achievement.ID] = true
+
+		if p.haveBaseline {
+			p.hub.Publish(events.TypeAchievementUnlocked, status)
+		}
+	}
+	p.haveBaseline = true
+}
+
+// GET /api/events - Server-Sent Events поток обновлений
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := s.events.Subscribe()
+	defer s.events.Unsubscribe(ch)
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+			flusher.Flush()
+		}
+	}
+}
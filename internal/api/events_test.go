@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"nlbw-ui/internal/achievements"
+	"nlbw-ui/internal/aggregator"
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/config"
+	"nlbw-ui/internal/converter"
+	"nlbw-ui/internal/events"
+)
+
+// trafficRow - строка TrafficData в формате converter
+func trafficRow(mac, ip string, rx, tx uint64) []interface{} {
+	return []interface{}{4, "TCP", uint16(443), mac, ip, uint64(1), rx, uint64(1), tx, uint64(1), nil}
+}
+
+func trafficData(rows ...[]interface{}) *converter.TrafficData {
+	return &converter.TrafficData{Data: rows}
+}
+
+// drain забирает все накопившиеся события
+func drain(ch chan events.Event) []events.Event {
+	var result []events.Event
+	for {
+		select {
+		case event := <-ch:
+			result = append(result, event)
+		default:
+			return result
+		}
+	}
+}
+
+func eventsOfType(list []events.Event, eventType string) []events.Event {
+	var result []events.Event
+	for _, event := range list {
+		if event.Type == eventType {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+func newTestPublisher(c *cache.Cache) (*eventPublisher, chan events.Event) {
+	hub := events.NewHub()
+	agg := aggregator.New(c, &config.Config{})
+	calc := achievements.NewCalculator(c, agg, &config.Config{})
+	p := newEventPublisher(c, hub, agg, calc)
+	return p, hub.Subscribe()
+}
+
+func TestEventPublisher_FileEvents(t *testing.T) {
+	c := cache.New()
+	_, ch := newTestPublisher(c)
+	key := cache.Key("main", "/data/20240101.db.gz")
+
+	c.Set(key, trafficData(trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 100, 10)))
+	first := drain(ch)
+	if len(eventsOfType(first, events.TypeFileLoaded)) != 1 || len(eventsOfType(first, events.TypeFileModified)) != 0 {
+		t.Errorf("Expected file_loaded for a new file, got %+v", first)
+	}
+
+	var file FileEvent
+	json.Unmarshal(eventsOfType(first, events.TypeFileLoaded)[0].Data, &file)
+	if file.Source != "main" || file.File != "20240101.db.gz" || file.Date != "2024-01-01" {
+		t.Errorf("Unexpected file event: %+v", file)
+	}
+
+	c.Set(key, trafficData(trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 200, 20)))
+	second := drain(ch)
+	if len(eventsOfType(second, events.TypeFileModified)) != 1 || len(eventsOfType(second, events.TypeFileLoaded)) != 0 {
+		t.Errorf("Expected file_modified for a reloaded file, got %+v", second)
+	}
+
+	updated := eventsOfType(second, events.TypeDayUpdated)
+	if len(updated) != 1 {
+		t.Fatalf("Expected day_updated, got %+v", second)
+	}
+	var day aggregator.CalendarDay
+	json.Unmarshal(updated[0].Data, &day)
+	if day.Date != "2024-01-01" || day.Downloaded != 200 || day.Uploaded != 20 {
+		t.Errorf("Unexpected day totals: %+v", day)
+	}
+}
+
+func TestEventPublisher_DeviceNewOncePerMAC(t *testing.T) {
+	c := cache.New()
+	// Устройства из уже загруженных файлов не новые
+	c.Set(cache.Key("main", "/data/20240101.db.gz"), trafficData(trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 1, 1)))
+	_, ch := newTestPublisher(c)
+
+	c.Set(cache.Key("main", "/data/20240102.db.gz"), trafficData(
+		trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 1, 1),
+		trafficRow("bb:bb:bb:bb:bb:bb", "192.168.1.20", 1, 1),
+		trafficRow("bb:bb:bb:bb:bb:bb", "192.168.1.20", 1, 1),
+	))
+	c.Set(cache.Key("main", "/data/20240103.db.gz"), trafficData(trafficRow("bb:bb:bb:bb:bb:bb", "192.168.1.21", 1, 1)))
+
+	devices := eventsOfType(drain(ch), events.TypeDeviceNew)
+	if len(devices) != 1 {
+		t.Fatalf("Expected one device_new, got %d", len(devices))
+	}
+	var device DeviceEvent
+	json.Unmarshal(devices[0].Data, &device)
+	if device.MAC != "bb:bb:bb:bb:bb:bb" || device.IP != "192.168.1.20" || device.Date != "2024-01-02" {
+		t.Errorf("Unexpected device event: %+v", device)
+	}
+}
+
+func TestEventPublisher_AchievementBaseline(t *testing.T) {
+	c := cache.New()
+	// Уже разблокированное до запуска достижение не отправляется
+	c.Set(cache.Key("main", "/data/20240101.db.gz"), trafficData(
+		trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 2*achievements.OneGigabyte, 0),
+	))
+	p, ch := newTestPublisher(c)
+	p.checkAchievements()
+
+	c.Set(cache.Key("main", "/data/20240102.db.gz"), trafficData(
+		trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 20*achievements.OneGigabyte, 0),
+	))
+	p.checkAchievements()
+
+	unlocked := make(map[string]int)
+	for _, event := range eventsOfType(drain(ch), events.TypeAchievementUnlocked) {
+		var status achievements.AchievementStatus
+		json.Unmarshal(event.Data, &status)
+		unlocked[status.Achievement.ID]++
+	}
+
+	if unlocked[achievements.AchievementFirstGigabyte] != 0 {
+		t.Error("Achievement unlocked before start must be part of the baseline")
+	}
+	if unlocked[achievements.AchievementDailyBurner] != 1 {
+		t.Errorf("Expected daily_burner once, got %v", unlocked)
+	}
+}
+
+func TestEventPublisher_AnomalyOnlyForToday(t *testing.T) {
+	c := cache.New()
+	p, ch := newTestPublisher(c)
+	p.now = func() time.Time { return time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local) }
+
+	// Четыре недели обычного трафика и всплеск в прошлом - как при загрузке истории
+	start, end := time.Date(2024, 2, 10, 0, 0, 0, 0, time.Local), time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		rx := uint64(100 << 20)
+		if day.Day() == 5 {
+			rx = 10 << 30
+		}
+		c.Set(cache.Key("main", "/data/"+day.Format("20060102")+".db.gz"), trafficData(
+			trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", rx, 1<<20),
+		))
+	}
+	if p.anomalyTimer != nil {
+		t.Fatal("Backfilled days must not schedule an anomaly check")
+	}
+
+	// Сегодняшний файл обновляется несколько раз
+	today := cache.Key("main", "/data/20240310.db.gz")
+	for _, rx := range []uint64{5 << 30, 6 << 30} {
+		c.Set(today, trafficData(trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", rx, 1<<20)))
+		p.checkAnomalies()
+	}
+	if p.anomalyTimer == nil {
+		t.Error("Today's update must schedule an anomaly check")
+	} else {
+		p.anomalyTimer.Stop()
+	}
+
+	published := eventsOfType(drain(ch), events.TypeAnomaly)
+	if len(published) != 1 {
+		t.Fatalf("Expected one anomaly event, got %d", len(published))
+	}
+	var anomaly aggregator.Anomaly
+	json.Unmarshal(published[0].Data, &anomaly)
+	if anomaly.Date != "2024-03-10" || anomaly.Metric != aggregator.AnomalyDownloaded {
+		t.Errorf("Unexpected anomaly: %+v", anomaly)
+	}
+
+	// Новый день - отправленные за прошлый забываются
+	p.now = func() time.Time { return time.Date(2024, 3, 11, 12, 0, 0, 0, time.Local) }
+	p.checkAnomalies()
+	if p.anomalyDate != "2024-03-11" || len(p.anomalies) != 0 {
+		t.Errorf("Expected sent anomalies to reset for a new day, got %s %v", p.anomalyDate, p.anomalies)
+	}
+}
+
+func TestEventPublisher_NoSubscribers(t *testing.T) {
+	c := cache.New()
+	hub := events.NewHub()
+	agg := aggregator.New(c, &config.Config{})
+	p := newEventPublisher(c, hub, agg, achievements.NewCalculator(c, agg, &config.Config{}))
+	p.now = func() time.Time { return time.Date(2024, 1, 2, 12, 0, 0, 0, time.Local) }
+
+	// Без слушателей проверки не планируются, но устройство запоминается
+	c.Set(cache.Key("main", "/data/20240102.db.gz"), trafficData(trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 1, 1)))
+	if p.anomalyTimer != nil || p.achTimer != nil {
+		t.Error("No checks must be scheduled without subscribers")
+	}
+
+	ch := hub.Subscribe()
+	c.Set(cache.Key("main", "/data/20240102.db.gz"), trafficData(trafficRow("aa:aa:aa:aa:aa:aa", "192.168.1.10", 2, 2)))
+	received := drain(ch)
+	if len(eventsOfType(received, events.TypeDeviceNew)) != 0 {
+		t.Error("Device seen without subscribers must not be reported as new later")
+	}
+	if len(eventsOfType(received, events.TypeFileModified)) != 1 {
+		t.Errorf("Expected file_modified once a client is connected, got %+v", received)
+	}
+	p.anomalyTimer.Stop()
+	p.achTimer.Stop()
+}
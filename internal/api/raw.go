@@ -0,0 +1,356 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"nlbw-ui/internal/config"
+	"nlbw-ui/internal/converter"
+)
+
+// maxUploadSize - ограничение размера загружаемой базы
+const maxUploadSize = 256 << 20
+
+// fileExistsMessage - ответ на загрузку поверх существующего файла без overwrite=1
+const fileExistsMessage = "file already exists, use overwrite=1 to replace it"
+
+// rawSource возвращает источник из параметра source= (по умолчанию первый).
+// Для неизвестного источника пишет 400 и возвращает false.
+func (s *Server) rawSource(w http.ResponseWriter, r *http.Request) (config.SourceConfig, bool) {
+	cfg := s.aggregator.Config()
+
+	name := r.URL.Query().Get("source")
+	if name == "" {
+		if len(cfg.Sources) == 0 {
+			http.Error(w, "no sources configured", http.StatusNotFound)
+			return config.SourceConfig{}, false
+		}
+		return cfg.Sources[0], true
+	}
+
+	src, ok := cfg.GetSource(name)
+	if !ok {
+		http.Error(w, "unknown source: "+name, http.StatusBadRequest)
+		return config.SourceConfig{}, false
+	}
+	return src, true
+}
+
+// requireAdmin проверяет заголовок Authorization: Bearer <admin_token>.
+// Без admin_token в конфиге защищённые эндпоинты отключены.
+func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := s.aggregator.Config().AdminToken
+	if token == "" {
+		http.Error(w, "admin_token is not configured", http.StatusForbidden)
+		return false
+	}
+
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// isRawFileName проверяет имя вида YYYYMMDD.<поддерживаемое расширение>
+func isRawFileName(name string) bool {
+	base, ok := converter.TrimExtension(name)
+	if !ok || len(base) != 8 {
+		return false
+	}
+	for _, ch := range base {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GET /api/raw - список исходных файлов источника ({"files": [...]})
+// POST /api/raw - загрузка базы YYYYMMDD.db.gz в data_dir (нужен admin_token)
+// Формат списка совместим с источником type: http другого экземпляра.
+func (s *Server) handleRaw(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleListRaw(w, r)
+	case http.MethodPost:
+		s.handleUploadRaw(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleListRaw(w http.ResponseWriter, r *http.Request) {
+	src, ok := s.rawSource(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := os.ReadDir(src.DataDir)
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, "failed to list data_dir", http.StatusInternalServerError)
+		return
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isRawFileName(entry.Name()) {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"files": files,
+	})
+}
+
+// GET /api/raw/YYYYMMDD.db.gz - исходный файл из data_dir без преобразований.
+// Поддерживает If-None-Match/If-Modified-Since.
+func (s *Server) handleGetRawFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/raw/")
+	if !isRawFileName(name) {
+		http.Error(w, "invalid file name, use /api/raw/YYYYMMDD.db.gz", http.StatusBadRequest)
+		return
+	}
+
+	src, ok := s.rawSource(w, r)
+	if !ok {
+		return
+	}
+
+	file, err := os.Open(filepath.Join(src.DataDir, name))
+	if err != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
+
+// handleUploadRaw принимает базу либо как multipart поле file,
+// либо телом запроса с параметром name=YYYYMMDD.db.gz.
+// Существующий файл перезаписывается только с overwrite=1.
+func (s *Server) handleUploadRaw(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+
+	src, ok := s.rawSource(w, r)
+	if !ok {
+		return
+	}
+	if src.Type == config.SourceHTTP {
+		http.Error(w, "source is mirrored from "+src.URL+", upload there", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	name := r.URL.Query().Get("name")
+	var body io.Reader = r.Body
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "multipart field file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		if name == "" {
+			name = filepath.Base(header.Filename)
+		}
+		body = file
+	}
+
+	if !isRawFileName(name) || !strings.HasSuffix(name, ".db.gz") {
+		http.Error(w, "invalid file name, expected YYYYMMDD.db.gz", http.StatusBadRequest)
+		return
+	}
+
+	target := filepath.Join(src.DataDir, name)
+	overwrite := r.URL.Query().Get("overwrite") == "1"
+	// Быстрая проверка, чтобы не читать тело зря; окончательно
+	// существование файла проверяется атомарно при сохранении
+	if _, err := os.Stat(target); err == nil && !overwrite {
+		http.Error(w, fileExistsMessage, http.StatusConflict)
+		return
+	}
+
+	entries, err := writeValidatedDatabase(target, body, overwrite)
+	var invalid *uploadError
+	switch {
+	case errors.Is(err, fs.ErrExist):
+		http.Error(w, fileExistsMessage, http.StatusConflict)
+		return
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		fmt.Printf("Upload of %s to %s failed: %v\n", name, src.DataDir, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("Uploaded %s to %s (%d records)\n", name, src.DataDir, entries)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"source":  src.Name,
+		"file":    name,
+		"entries": entries,
+	})
+}
+
+// uploadError - ошибка в присланных данных, а не на стороне сервера (400, а не 500)
+type uploadError struct {
+	err error
+}
+
+func (e *uploadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *uploadError) Unwrap() error {
+	return e.err
+}
+
+// uploadReader помечает ошибки чтения тела запроса как uploadError,
+// чтобы отличить их от ошибок записи на диск
+type uploadReader struct {
+	reader io.Reader
+}
+
+func (r uploadReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = &uploadError{fmt.Errorf("failed to read upload: %w", err)}
+	}
+	return n, err
+}
+
+// linkFile - os.Link, подменяется в тестах
+var linkFile = os.Link
+
+// writeValidatedDatabase сохраняет поток во временный файл рядом с target,
+// проверяет его как базу nlbwmon и только после этого переносит на место.
+// Без overwrite файл ставится через жёсткую ссылку, которая не заменяет
+// существующий target - тогда возвращается ошибка fs.ErrExist.
+// Ошибки в самих данных возвращаются как *uploadError.
+// Сканер не видит временный файл, т.к. его имя не заканчивается на .db.gz.
+func writeValidatedDatabase(target string, body io.Reader, overwrite bool) (uint32, error) {
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create data_dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, uploadReader{body}); err != nil {
+		tmp.Close()
+		var invalid *uploadError
+		if errors.As(err, &invalid) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+
+	db, err := converter.New().ValidateGzip(tmp)
+	if err != nil {
+		tmp.Close()
+		return 0, &uploadError{fmt.Errorf("invalid database: %w", err)}
+	}
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if !overwrite {
+		err := linkFile(tmp.Name(), target)
+		if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP) {
+			// ФС без жёстких ссылок (FAT/exFAT флешки, часть FUSE) - создаём target эксклюзивно
+			err = copyExclusive(tmp.Name(), target)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to save file: %w", err)
+		}
+		return db.Entries, nil
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return 0, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return db.Entries, nil
+}
+
+// copyExclusive копирует src в новый файл dst; существующий dst не трогается (fs.ErrExist).
+// В отличие от ссылки файл появляется не атомарно - недописанный файл сканер
+// прочитает как обрезанный и перечитает после изменения.
+func copyExclusive(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
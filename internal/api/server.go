@@ -1,36 +1,130 @@
 package api
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
+	"net"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"nlbw-ui/internal/achievements"
 	"nlbw-ui/internal/aggregator"
 	"nlbw-ui/internal/cache"
 	"nlbw-ui/internal/config"
+	"nlbw-ui/internal/events"
 )
 
 type Server struct {
-	cache       *cache.Cache
-	aggregator  *aggregator.Aggregator
-	calculator  *achievements.Calculator
-	frontendFS  embed.FS
+	cache      *cache.Cache
+	aggregator *aggregator.Aggregator
+	frontendFS embed.FS
+	events     *events.Hub
+	onReload   func() error
+
+	mu         sync.Mutex
+	httpServer *http.Server
+	done       chan error
+
+	calcMu      sync.Mutex
+	calculators map[string]*achievements.Calculator // по источнику, "" = вся сеть
 }
 
 func New(c *cache.Cache, cfg *config.Config, frontendFS embed.FS) *Server {
 	agg := aggregator.New(c, cfg)
+	calc := achievements.NewCalculator(c, agg, cfg)
+	hub := events.NewHub()
+	newEventPublisher(c, hub, agg, calc)
+
 	return &Server{
 		cache:      c,
 		aggregator: agg,
-		calculator: achievements.NewCalculator(c, agg, cfg),
 		frontendFS: frontendFS,
+		events:     hub,
+		done:       make(chan error, 1),
+		calculators: map[string]*achievements.Calculator{
+			"": calc,
+		},
+	}
+}
+
+// SetConfig атомарно подменяет конфиг в aggregator и calculator
+func (s *Server) SetConfig(cfg *config.Config) {
+	s.aggregator.SetConfig(cfg)
+
+	s.calcMu.Lock()
+	defer s.calcMu.Unlock()
+	for _, calc := range s.calculators {
+		calc.SetConfig(cfg)
+	}
+}
+
+// aggregatorFor возвращает агрегатор с учётом параметра source=.
+// Для неизвестного источника пишет 400 и возвращает false.
+func (s *Server) aggregatorFor(w http.ResponseWriter, r *http.Request) (*aggregator.Aggregator, bool) {
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		return s.aggregator, true
+	}
+
+	if !s.knownSource(source) {
+		http.Error(w, "unknown source: "+source, http.StatusBadRequest)
+		return nil, false
+	}
+
+	return s.aggregator.ForSource(source), true
+}
+
+// withIPFilter применяет к агрегатору параметры ips=addr1,addr2 и cidr=net1,net2.
+// При ошибке отвечает 400 и возвращает false.
+func withIPFilter(w http.ResponseWriter, r *http.Request, agg *aggregator.Aggregator) (*aggregator.Aggregator, bool) {
+	query := r.URL.Query()
+	filter, err := aggregator.ParseIPFilter(query.Get("ips"), query.Get("cidr"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return agg.WithIPFilter(filter), true
+}
+
+// knownSource проверяет, что источник есть в конфиге или в кэше (demo)
+func (s *Server) knownSource(source string) bool {
+	if _, ok := s.aggregator.Config().GetSource(source); ok {
+		return true
+	}
+	for _, name := range s.cache.Sources() {
+		if name == source {
+			return true
+		}
 	}
+	return false
+}
+
+// calculatorFor возвращает калькулятор достижений для источника агрегатора.
+// Разблокированные достижения кэшируются отдельно для каждого источника.
+func (s *Server) calculatorFor(agg *aggregator.Aggregator) *achievements.Calculator {
+	s.calcMu.Lock()
+	defer s.calcMu.Unlock()
+
+	calc, ok := s.calculators[agg.Source()]
+	if !ok {
+		calc = achievements.NewCalculator(s.cache, agg, agg.Config())
+		s.calculators[agg.Source()] = calc
+	}
+	return calc
+}
+
+// OnReload задаёт обработчик для POST /api/admin/reload
+func (s *Server) OnReload(fn func() error) {
+	s.onReload = fn
 }
 
 func (s *Server) setupRoutes() *http.ServeMux {
@@ -43,10 +137,30 @@ func (s *Server) setupRoutes() *http.ServeMux {
 	mux.HandleFunc("/api/device/", s.handleGetDevice)
 	mux.HandleFunc("/api/timeseries", s.handleGetTimeseries)
 	mux.HandleFunc("/api/device-protocols", s.handleGetDeviceProtocolsRange)
+	mux.HandleFunc("/api/compare", s.handleGetCompare)
+	mux.HandleFunc("/api/top", s.handleGetTop)
+	mux.HandleFunc("/api/protocols", s.handleGetProtocols)
+	mux.HandleFunc("/api/categories", s.handleGetCategories)
+	mux.HandleFunc("/api/families", s.handleGetFamilies)
+	mux.HandleFunc("/api/ip/", s.handleGetIP)
+	mux.HandleFunc("/api/segments/summary", s.handleGetSegmentsSummary)
+	mux.HandleFunc("/api/anomalies", s.handleGetAnomalies)
+	mux.HandleFunc("/api/sources", s.handleGetSources)
+	mux.HandleFunc("/api/health/files", s.handleGetFilesHealth)
+
+	// Raw database files (download/upload)
+	mux.HandleFunc("/api/raw", s.handleRaw)
+	mux.HandleFunc("/api/raw/", s.handleGetRawFile)
 
 	// Achievements endpoint
 	mux.HandleFunc("/api/achievements", s.handleGetAchievements)
 
+	// Live updates (Server-Sent Events)
+	mux.HandleFunc("/api/events", s.handleEvents)
+
+	// Admin endpoints
+	mux.HandleFunc("/api/admin/reload", s.handleAdminReload)
+
 	// Old endpoints (keep for compatibility)
 	mux.HandleFunc("/api/files", s.handleGetFiles)
 	mux.HandleFunc("/api/data/", s.handleGetData)
@@ -62,17 +176,74 @@ func (s *Server) setupRoutes() *http.ServeMux {
 	return mux
 }
 
+// Start запускает HTTP сервер и блокируется до его фатальной ошибки.
+// Смена адреса через Rebind не приводит к выходу из Start.
 func (s *Server) Start(addr string) error {
 	mux := s.setupRoutes()
+	handler := s.corsMiddleware(mux)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
 	fmt.Printf("Starting server on %s\n", addr)
-	return http.ListenAndServe(addr, s.corsMiddleware(mux))
+	s.serve(listener, handler)
+
+	return <-s.done
+}
+
+// Rebind переносит сервер на новый адрес без остановки процесса.
+// Новый listener открывается до закрытия старого, поэтому при ошибке
+// сервер продолжает работать на прежнем адресе.
+func (s *Server) Rebind(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	s.mu.Lock()
+	old := s.httpServer
+	s.mu.Unlock()
+
+	if old == nil {
+		listener.Close()
+		return fmt.Errorf("server is not running")
+	}
+
+	fmt.Printf("Moving server to %s\n", addr)
+	s.serve(listener, old.Handler)
+
+	// Долгоживущие соединения (SSE) не завершатся сами - закрываем их принудительно
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := old.Shutdown(ctx); err != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+func (s *Server) serve(listener net.Listener, handler http.Handler) {
+	srv := &http.Server{Handler: handler}
+
+	s.mu.Lock()
+	s.httpServer = srv
+	s.mu.Unlock()
+
+	go func() {
+		err := srv.Serve(listener)
+		if errors.Is(err, http.ErrServerClosed) {
+			return
+		}
+		s.done <- err
+	}()
 }
 
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -85,7 +256,19 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 
 // GET /api/calendar - данные для матрицы активности
 // Опциональный параметр: macs=mac1,mac2 для фильтрации по устройствам
+// Calendar, summary, timeseries и top принимают ips=addr1,addr2 и cidr=192.168.2.0/24
+// для фильтрации по колонке ip (IPv4 и IPv6)
+// Все эндпоинты ниже принимают source=имя для фильтрации по источнику
 func (s *Server) handleGetCalendar(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+	agg, ok = withIPFilter(w, r, agg)
+	if !ok {
+		return
+	}
+
 	macsParam := r.URL.Query().Get("macs")
 
 	var macs []string
@@ -93,13 +276,22 @@ func (s *Server) handleGetCalendar(w http.ResponseWriter, r *http.Request) {
 		macs = strings.Split(macsParam, ",")
 	}
 
-	data := s.aggregator.GetCalendarData(macs)
+	data := agg.GetCalendarData(macs)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
 
 // GET /api/summary?from=YYYY-MM-DD&to=YYYY-MM-DD
 func (s *Server) handleGetSummary(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+	agg, ok = withIPFilter(w, r, agg)
+	if !ok {
+		return
+	}
+
 	from := r.URL.Query().Get("from")
 	to := r.URL.Query().Get("to")
 
@@ -110,20 +302,25 @@ func (s *Server) handleGetSummary(w http.ResponseWriter, r *http.Request) {
 		from = now.AddDate(0, 0, -30).Format("2006-01-02")
 	}
 
-	summary := s.aggregator.GetSummary(from, to)
+	summary := agg.GetSummary(from, to)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(summary)
 }
 
 // GET /api/day/YYYY-MM-DD
 func (s *Server) handleGetDay(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
 	date := strings.TrimPrefix(r.URL.Path, "/api/day/")
 	if date == "" {
 		http.Error(w, "date is required", http.StatusBadRequest)
 		return
 	}
 
-	dayStats := s.aggregator.GetDayStats(date)
+	dayStats := agg.GetDayStats(date)
 	if dayStats == nil {
 		http.Error(w, "data not found for this date", http.StatusNotFound)
 		return
@@ -135,6 +332,11 @@ func (s *Server) handleGetDay(w http.ResponseWriter, r *http.Request) {
 
 // GET /api/device/YYYY-MM-DD/MAC
 func (s *Server) handleGetDevice(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
 	path := strings.TrimPrefix(r.URL.Path, "/api/device/")
 	parts := strings.SplitN(path, "/", 2)
 
@@ -146,7 +348,7 @@ func (s *Server) handleGetDevice(w http.ResponseWriter, r *http.Request) {
 	date := parts[0]
 	mac := parts[1]
 
-	protocols := s.aggregator.GetDeviceProtocols(date, mac)
+	protocols := agg.GetDeviceProtocols(date, mac)
 	if protocols == nil {
 		http.Error(w, "data not found", http.StatusNotFound)
 		return
@@ -156,8 +358,26 @@ func (s *Server) handleGetDevice(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(protocols)
 }
 
-// GET /api/timeseries?from=...&to=...&macs=mac1,mac2
+// GET /api/timeseries?from=...&to=...&macs=mac1,mac2&segment=guest,iot
+// Опционально: bucket=day|week|month|year, week_start=monday (ISO недели по умолчанию),
+// devices=false - без трафика по устройствам
 func (s *Server) handleGetTimeseries(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+	agg, ok = withIPFilter(w, r, agg)
+	if !ok {
+		return
+	}
+	if segments := r.URL.Query().Get("segment"); segments != "" {
+		var err error
+		if agg, err = agg.WithSegments(strings.Split(segments, ",")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	from := r.URL.Query().Get("from")
 	to := r.URL.Query().Get("to")
 	macsParam := r.URL.Query().Get("macs")
@@ -174,14 +394,88 @@ func (s *Server) handleGetTimeseries(w http.ResponseWriter, r *http.Request) {
 		macs = strings.Split(macsParam, ",")
 	}
 
-	timeseries := s.aggregator.GetTimeseries(from, to, macs)
+	query := r.URL.Query()
+	if query.Get("bucket") == "" && query.Get("week_start") == "" && query.Get("devices") == "" {
+		// Прежний ответ: по одному DayStats на день
+		timeseries := agg.GetTimeseries(from, to, macs)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(timeseries)
+		return
+	}
+
+	opts, err := timeseriesOptions(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timeseries := agg.GetBucketedTimeseries(from, to, macs, opts)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(timeseries)
 }
 
+// timeseriesOptions читает параметры bucket, week_start и devices
+func timeseriesOptions(query url.Values) (aggregator.TimeseriesOptions, error) {
+	opts := aggregator.TimeseriesOptions{Devices: true}
+
+	var err error
+	if opts.Bucket, err = aggregator.ParseBucket(query.Get("bucket")); err != nil {
+		return opts, err
+	}
+	if opts.WeekStart, err = aggregator.ParseWeekday(query.Get("week_start")); err != nil {
+		return opts, err
+	}
+	if value := query.Get("devices"); value != "" {
+		if opts.Devices, err = strconv.ParseBool(value); err != nil {
+			return opts, fmt.Errorf("devices must be true or false")
+		}
+	}
+	return opts, nil
+}
+
+// GET /api/families?from=...&to=...&macs=...&bucket=day|week|month|year&week_start=...
+// Трафик IPv4/IPv6 и доля IPv6 во времени
+func (s *Server) handleGetFamilies(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	from := query.Get("from")
+	to := query.Get("to")
+
+	// Defaults: last 30 days
+	if from == "" || to == "" {
+		now := time.Now()
+		to = now.Format("2006-01-02")
+		from = now.AddDate(0, 0, -30).Format("2006-01-02")
+	}
+
+	var macs []string
+	if macsParam := query.Get("macs"); macsParam != "" {
+		macs = strings.Split(macsParam, ",")
+	}
+
+	opts, err := timeseriesOptions(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	families := agg.GetFamilies(from, to, macs, opts)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(families)
+}
+
 // GET /api/device-protocols?from=...&to=...&mac=...
 // Агрегированные протоколы устройства за диапазон дат
 func (s *Server) handleGetDeviceProtocolsRange(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
 	from := r.URL.Query().Get("from")
 	to := r.URL.Query().Get("to")
 	mac := r.URL.Query().Get("mac")
@@ -198,22 +492,370 @@ func (s *Server) handleGetDeviceProtocolsRange(w http.ResponseWriter, r *http.Re
 		from = now.AddDate(0, 0, -30).Format("2006-01-02")
 	}
 
-	protocols := s.aggregator.GetDeviceProtocolsRange(from, to, mac)
+	protocols := agg.GetDeviceProtocolsRange(from, to, mac)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocols)
+}
+
+// GET /api/compare?a_from=...&a_to=...&b_from=...&b_to=...&macs=mac1,mac2
+// Сравнение двух периодов: изменения по устройствам и протоколам
+func (s *Server) handleGetCompare(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	aFrom, aTo := query.Get("a_from"), query.Get("a_to")
+	bFrom, bTo := query.Get("b_from"), query.Get("b_to")
+
+	for _, period := range [][2]string{{aFrom, aTo}, {bFrom, bTo}} {
+		from, errFrom := time.Parse("2006-01-02", period[0])
+		to, errTo := time.Parse("2006-01-02", period[1])
+		if errFrom != nil || errTo != nil {
+			http.Error(w, "a_from, a_to, b_from and b_to are required (YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		if to.Before(from) {
+			http.Error(w, "period end must not be before its start", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var macs []string
+	if macsParam := query.Get("macs"); macsParam != "" {
+		macs = strings.Split(macsParam, ",")
+	}
+
+	comparison := agg.Compare(aFrom, aTo, bFrom, bTo, macs)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// GET /api/top?from=...&to=...&by=downloaded|uploaded|total|connections|packets&dimension=device|protocol|port|ip&limit=N
+// Рейтинг за период: первые limit позиций и остальное одной строкой other
+func (s *Server) handleGetTop(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+	agg, ok = withIPFilter(w, r, agg)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	from := query.Get("from")
+	to := query.Get("to")
+
+	// Defaults: last 30 days
+	if from == "" || to == "" {
+		now := time.Now()
+		to = now.Format("2006-01-02")
+		from = now.AddDate(0, 0, -30).Format("2006-01-02")
+	}
+
+	opts := aggregator.TopOptions{
+		By:        query.Get("by"),
+		Dimension: query.Get("dimension"),
+		Limit:     10,
+	}
+	if opts.By == "" {
+		opts.By = aggregator.TopByTotal
+	}
+	if opts.Dimension == "" {
+		opts.Dimension = aggregator.TopDevice
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "limit must be a number", http.StatusBadRequest)
+			return
+		}
+		opts.Limit = limit
+	}
+	if err := aggregator.ValidateTopOptions(opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	top := agg.Top(from, to, opts)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(top)
+}
+
+// GET /api/protocols?from=...&to=...&macs=mac1,mac2
+// Протоколы и порты всей сети с названиями сервисов и списком устройств
+func (s *Server) handleGetProtocols(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	macsParam := r.URL.Query().Get("macs")
+
+	// Defaults: last 30 days
+	if from == "" || to == "" {
+		now := time.Now()
+		to = now.Format("2006-01-02")
+		from = now.AddDate(0, 0, -30).Format("2006-01-02")
+	}
+
+	var macs []string
+	if macsParam != "" {
+		macs = strings.Split(macsParam, ",")
+	}
+
+	protocols := agg.GetProtocols(from, to, macs)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(protocols)
 }
 
+// GET /api/categories?from=...&to=...&macs=mac1,mac2
+// Трафик по категориям приложений (правила из конфига + таблица сервисов)
+func (s *Server) handleGetCategories(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	macsParam := r.URL.Query().Get("macs")
+
+	// Defaults: last 30 days
+	if from == "" || to == "" {
+		now := time.Now()
+		to = now.Format("2006-01-02")
+		from = now.AddDate(0, 0, -30).Format("2006-01-02")
+	}
+
+	var macs []string
+	if macsParam != "" {
+		macs = strings.Split(macsParam, ",")
+	}
+
+	categories := agg.GetCategories(from, to, macs)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// GET /api/segments/summary?from=...&to=...
+// Трафик по сегментам сети из конфига (segments), неопознанные записи - в other
+func (s *Server) handleGetSegmentsSummary(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+
+	// Defaults: last 30 days
+	if from == "" || to == "" {
+		now := time.Now()
+		to = now.Format("2006-01-02")
+		from = now.AddDate(0, 0, -30).Format("2006-01-02")
+	}
+
+	summary := agg.GetSegmentSummary(from, to)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// GET /api/anomalies?from=...&to=...&window=28&threshold=6
+// Дни, когда трафик, соединения или порты устройства резко отличались от его базовой линии
+func (s *Server) handleGetAnomalies(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	from := query.Get("from")
+	to := query.Get("to")
+
+	// Defaults: last 30 days
+	if from == "" || to == "" {
+		now := time.Now()
+		to = now.Format("2006-01-02")
+		from = now.AddDate(0, 0, -30).Format("2006-01-02")
+	}
+
+	opts := aggregator.AnomalyOptions{
+		Window:    aggregator.DefaultAnomalyWindow,
+		Threshold: aggregator.DefaultAnomalyThreshold,
+	}
+	if value := query.Get("window"); value != "" {
+		window, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "window must be a number", http.StatusBadRequest)
+			return
+		}
+		opts.Window = window
+	}
+	if value := query.Get("threshold"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			http.Error(w, "threshold must be a number", http.StatusBadRequest)
+			return
+		}
+		opts.Threshold = threshold
+	}
+	if err := aggregator.ValidateAnomalyOptions(opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := agg.DetectAnomalies(from, to, opts)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GET /api/ip/{addr}?date=YYYY-MM-DD или ?from=...&to=...
+// Какие устройства использовали адрес (без дат - за всё время)
+func (s *Server) handleGetIP(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	ip := aggregator.NormalizeIP(strings.TrimPrefix(r.URL.Path, "/api/ip/"))
+	if ip == "" {
+		http.Error(w, "invalid ip address, use /api/ip/ADDRESS", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if date := query.Get("date"); date != "" {
+		from, to = date, date
+	}
+	if (from == "") != (to == "") {
+		http.Error(w, "from and to must be used together", http.StatusBadRequest)
+		return
+	}
+
+	lookup := agg.LookupIP(ip, from, to)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lookup)
+}
+
 // GET /api/achievements - достижения для всей сети
 func (s *Server) handleGetAchievements(w http.ResponseWriter, r *http.Request) {
-	networkAchievements := s.calculator.GetNetworkAchievements()
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	networkAchievements := s.calculatorFor(agg).GetNetworkAchievements()
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(networkAchievements)
 }
 
+// POST /api/admin/reload - перечитать config.yaml без перезапуска (нужен admin_token)
+func (s *Server) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.requireAdmin(w, r) {
+		return
+	}
+
+	if s.onReload == nil {
+		http.Error(w, "reload is not supported", http.StatusNotImplemented)
+		return
+	}
+
+	if err := s.onReload(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+	})
+}
+
+// SourceInfo - описание источника для /api/sources
+type SourceInfo struct {
+	Name    string `json:"name"`
+	DataDir string `json:"data_dir,omitempty"`
+	Files   int    `json:"files"`
+}
+
+// GET /api/sources - список источников (роутеров) и количество загруженных файлов
+func (s *Server) handleGetSources(w http.ResponseWriter, r *http.Request) {
+	cfg := s.aggregator.Config()
+	result := make([]SourceInfo, 0, len(cfg.Sources))
+	listed := make(map[string]bool)
+
+	for _, src := range cfg.Sources {
+		result = append(result, SourceInfo{
+			Name:    src.Name,
+			DataDir: src.DataDir,
+			Files:   len(s.aggregator.ForSource(src.Name).Data()),
+		})
+		listed[src.Name] = true
+	}
+
+	// Источники, которых нет в конфиге (например, demo)
+	for _, name := range s.cache.Sources() {
+		if !listed[name] {
+			result = append(result, SourceInfo{
+				Name:  name,
+				Files: len(s.aggregator.ForSource(name).Data()),
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GET /api/health/files - статус загрузки каждого файла: ok, truncated,
+// retrying, error или quarantined, число записей и последняя ошибка
+func (s *Server) handleGetFilesHealth(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+	if source != "" && !s.knownSource(source) {
+		http.Error(w, "unknown source: "+source, http.StatusBadRequest)
+		return
+	}
+
+	files := make([]cache.FileStatus, 0)
+	counts := make(map[string]int)
+	for _, status := range s.cache.FileStatuses() {
+		if source != "" && status.Source != source {
+			continue
+		}
+		files = append(files, status)
+		counts[status.Status]++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"files":  files,
+		"counts": counts,
+	})
+}
+
 // Old endpoints below
 
 func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
-	files := s.cache.GetFilesList()
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	files := make([]string, 0)
+	for key := range agg.Data() {
+		files = append(files, filepath.Base(key))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"files": files,
@@ -221,13 +863,18 @@ func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetData(w http.ResponseWriter, r *http.Request) {
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
 	filename := strings.TrimPrefix(r.URL.Path, "/api/data/")
 	if filename == "" {
 		http.Error(w, "filename is required", http.StatusBadRequest)
 		return
 	}
 
-	allData := s.cache.GetAll()
+	allData := agg.Data()
 	for path, data := range allData {
 		if filepath.Base(path) == filename {
 			w.Header().Set("Content-Type", "application/json")
@@ -240,7 +887,12 @@ func (s *Server) handleGetData(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetAllData(w http.ResponseWriter, r *http.Request) {
-	allData := s.cache.GetAll()
+	agg, ok := s.aggregatorFor(w, r)
+	if !ok {
+		return
+	}
+
+	allData := agg.Data()
 	result := make(map[string]interface{})
 	for path, data := range allData {
 		result[filepath.Base(path)] = data
@@ -291,11 +943,23 @@ func (s *Server) handleFallback(w http.ResponseWriter, r *http.Request) {
 	<h2>API Endpoints:</h2>
 	<ul>
 		<li><a href="/api/calendar">/api/calendar</a> - Calendar heatmap data</li>
-		<li><a href="/api/summary">/api/summary</a> - Summary statistics (last 30 days)</li>
+		<li><a href="/api/summary">/api/summary</a> - Summary statistics (last 30 days); add ?cidr=192.168.2.0/24 or ?ips=... for a subnet</li>
 		<li>/api/day/YYYY-MM-DD - Day details</li>
 		<li>/api/device/YYYY-MM-DD/MAC - Device protocol breakdown</li>
-		<li>/api/timeseries - Timeseries data for charts</li>
+		<li>/api/timeseries - Timeseries data for charts (bucket=day|week|month|year, devices=false, segment=guest)</li>
+		<li><a href="/api/protocols">/api/protocols</a> - Network-wide protocol and port breakdown</li>
+		<li><a href="/api/categories">/api/categories</a> - Traffic by application category (web, streaming, VPN, ...)</li>
+		<li><a href="/api/families">/api/families</a> - IPv4/IPv6 traffic and IPv6 share over time</li>
+		<li><a href="/api/segments/summary">/api/segments/summary</a> - Traffic by network segment (lan, guest, iot, ...)</li>
+		<li><a href="/api/anomalies">/api/anomalies</a> - Days with unusual device traffic, connections or new ports (window, threshold)</li>
+		<li>/api/ip/ADDRESS?date=YYYY-MM-DD - Which device had an IP address</li>
+		<li><a href="/api/top">/api/top</a> - Top devices, protocols, ports or IPs (by, dimension, limit)</li>
+		<li>/api/compare?a_from=...&amp;a_to=...&amp;b_from=...&amp;b_to=... - Compare two periods</li>
+		<li><a href="/api/sources">/api/sources</a> - Data sources (routers); add ?source=name to any endpoint</li>
+		<li><a href="/api/health/files">/api/health/files</a> - Load status of each database file</li>
 		<li><a href="/api/achievements">/api/achievements</a> - Network achievements</li>
+		<li>/api/events - Live updates (Server-Sent Events)</li>
+		<li><a href="/api/raw">/api/raw</a> - Raw database files; /api/raw/YYYYMMDD.db.gz to download</li>
 		<li><a href="/api/files">/api/files</a> - List of files</li>
 	</ul>
 </body>
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/config"
+)
+
+// newTestServer создаёт сервер с пустым кэшем и возвращает его обработчик
+func newTestServer(t *testing.T, cfg *config.Config) (*Server, http.Handler) {
+	t.Helper()
+	s := New(cache.New(), cfg, embed.FS{})
+	return s, s.setupRoutes()
+}
+
+// do выполняет запрос к обработчику
+func do(handler http.Handler, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminReload_RequiresToken(t *testing.T) {
+	s, handler := newTestServer(t, &config.Config{})
+	reloads := 0
+	s.OnReload(func() error {
+		reloads++
+		return nil
+	})
+
+	if rec := do(handler, http.MethodPost, "/api/admin/reload", "secret"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without admin_token, got %d", rec.Code)
+	}
+
+	s.SetConfig(&config.Config{AdminToken: "secret"})
+	if rec := do(handler, http.MethodPost, "/api/admin/reload", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", rec.Code)
+	}
+	if rec := do(handler, http.MethodPost, "/api/admin/reload", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for wrong token, got %d", rec.Code)
+	}
+	if rec := do(handler, http.MethodGet, "/api/admin/reload", "secret"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", rec.Code)
+	}
+	if reloads != 0 {
+		t.Fatalf("Reload must not run without a valid token, ran %d times", reloads)
+	}
+
+	if rec := do(handler, http.MethodPost, "/api/admin/reload", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if reloads != 1 {
+		t.Errorf("Expected one reload, got %d", reloads)
+	}
+}
+
+func TestGetTop_Limit(t *testing.T) {
+	_, handler := newTestServer(t, &config.Config{})
+
+	for target, code := range map[string]int{
+		"/api/top?limit=1000": http.StatusOK,
+		"/api/top?limit=1001": http.StatusBadRequest,
+		"/api/top?limit=0":    http.StatusBadRequest,
+		"/api/top?limit=ten":  http.StatusBadRequest,
+	} {
+		if rec := do(handler, http.MethodGet, target, ""); rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", target, code, rec.Code)
+		}
+	}
+}
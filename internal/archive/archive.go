@@ -0,0 +1,333 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/config"
+	"nlbw-ui/internal/converter"
+)
+
+// Archiver копирует замороженные файлы источников в архив,
+// откуда они загружаются после удаления nlbwmon из data_dir.
+//
+// Структура архива:
+//
+//	<dir>/<source>/YYYYMMDD.db.gz         - исходные файлы за последние keep_days дней
+//	<dir>/<source>/YYYYMM.rollup.json.gz  - помесячные сводки для более старых дней
+type Archiver struct {
+	dir      string
+	keepDays int
+	cache    *cache.Cache
+
+	mu  sync.Mutex // сериализует операции с файлами архива
+	now func() time.Time
+}
+
+// New создаёт архиватор, загружающий архивные файлы в кэш c
+func New(cfg config.ArchiveConfig, c *cache.Cache) *Archiver {
+	return &Archiver{
+		dir:      cfg.Dir,
+		keepDays: cfg.KeepDays,
+		cache:    c,
+		now:      time.Now,
+	}
+}
+
+// Dir возвращает директорию архива источника
+func (a *Archiver) Dir(source string) string {
+	return filepath.Join(a.dir, source)
+}
+
+// Archive копирует замороженный файл источника в архив.
+// Уже заархивированный файл того же размера не копируется повторно.
+// В кэш копия не загружается - пока файл есть в data_dir, используется он.
+func (a *Archiver) Archive(source, path string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name := filepath.Base(path)
+	date, ok := fileDate(name)
+	if !ok {
+		return nil
+	}
+
+	// День уже сжат в помесячную сводку - исходник больше не нужен
+	if a.expired(date) {
+		rollup, err := readRollup(a.rollupPath(source, date))
+		if err == nil && rollup.Days[dayKey(date)] != nil {
+			return nil
+		}
+	}
+
+	target := filepath.Join(a.Dir(source), name)
+	src, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if dst, err := os.Stat(target); err == nil && dst.Size() == src.Size() {
+		return nil
+	}
+
+	if err := copyFile(path, target); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	fmt.Printf("Archived (%s): %s\n", source, name)
+	return nil
+}
+
+// Load загружает в кэш архивные файлы и сводки источника.
+// Дни, для которых skip возвращает true (они есть в data_dir), пропускаются.
+func (a *Archiver) Load(source string, skip func(day string) bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries, err := os.ReadDir(a.Dir(source))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(a.Dir(source), entry.Name())
+
+		if date, ok := fileDate(entry.Name()); ok {
+			if skip != nil && skip(dayKey(date)) {
+				continue
+			}
+			if err := a.cache.LoadFile(source, path); err != nil {
+				fmt.Printf("Error loading archived file %s: %v\n", path, err)
+			}
+			continue
+		}
+
+		if isRollupName(entry.Name()) {
+			// Несжатая сводка, уже заменённая сжатой, не загружается
+			if strings.HasSuffix(path, legacyRollupSuffix) {
+				if _, err := os.Stat(strings.TrimSuffix(path, legacyRollupSuffix) + rollupSuffix); err == nil {
+					continue
+				}
+			}
+			if err := a.loadRollup(source, path); err != nil {
+				fmt.Printf("Error loading rollup %s: %v\n", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadDay загружает в кэш архивную копию дня YYYYMMDD, если она есть.
+// Используется, когда nlbwmon удаляет файл из data_dir.
+func (a *Archiver) LoadDay(source, day string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, ext := range converter.Extensions {
+		path := filepath.Join(a.Dir(source), day+ext)
+		if _, err := os.Stat(path); err == nil {
+			return a.cache.LoadFile(source, path)
+		}
+	}
+	return nil
+}
+
+// Retain сжимает архивные файлы старше keep_days в помесячные сводки
+// и удаляет исходники. Каждый день в сводке остаётся отдельным.
+func (a *Archiver) Retain(source string) error {
+	if a.keepDays <= 0 {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries, err := os.ReadDir(a.Dir(source))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	// Группируем устаревшие файлы по месяцам
+	expired := make(map[string][]string)
+	for _, entry := range entries {
+		date, ok := fileDate(entry.Name())
+		if ok && a.expired(date) {
+			month := date.Format("200601")
+			expired[month] = append(expired[month], entry.Name())
+		}
+	}
+
+	months := make([]string, 0, len(expired))
+	for month := range expired {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	conv := converter.New()
+	for _, month := range months {
+		rollupPath := filepath.Join(a.Dir(source), month+rollupSuffix)
+
+		legacyPath := filepath.Join(a.Dir(source), month+legacyRollupSuffix)
+		legacy := false
+
+		rollup, err := readRollup(rollupPath)
+		if os.IsNotExist(err) {
+			// Несжатая сводка прежней версии переносится в сжатую
+			rollup, err = readRollup(legacyPath)
+			legacy = err == nil
+		}
+		if os.IsNotExist(err) {
+			rollup = &Rollup{Month: month, Days: make(map[string]*converter.TrafficData)}
+		} else if err != nil {
+			return fmt.Errorf("failed to read rollup %s: %w", month, err)
+		}
+
+		for _, name := range expired[month] {
+			data, err := conv.ConvertFile(filepath.Join(a.Dir(source), name))
+			if err != nil {
+				fmt.Printf("Warning: failed to read archived file %s: %v\n", name, err)
+				continue
+			}
+			date, _ := fileDate(name)
+			rollup.Days[dayKey(date)] = Downsample(data)
+		}
+
+		if err := writeRollup(rollupPath, rollup); err != nil {
+			return fmt.Errorf("failed to write rollup %s: %w", month, err)
+		}
+
+		// Сначала сводка в кэш, потом удаление исходников - данные не пропадают
+		if err := a.loadRollup(source, rollupPath); err != nil {
+			return err
+		}
+		if legacy {
+			for day := range rollup.Days {
+				a.cache.Delete(cache.Key(source, legacyPath+"/"+day))
+			}
+			if err := os.Remove(legacyPath); err != nil {
+				fmt.Printf("Warning: failed to remove %s: %v\n", legacyPath, err)
+			}
+		}
+		for _, name := range expired[month] {
+			date, _ := fileDate(name)
+			if rollup.Days[dayKey(date)] == nil {
+				continue
+			}
+			path := filepath.Join(a.Dir(source), name)
+			a.cache.Delete(cache.Key(source, path))
+			if err := os.Remove(path); err != nil {
+				fmt.Printf("Warning: failed to remove %s: %v\n", path, err)
+			}
+		}
+
+		fmt.Printf("Rolled up %d archived days (%s) into %s\n", len(expired[month]), source, filepath.Base(rollupPath))
+	}
+
+	return nil
+}
+
+// loadRollup кладёт каждый день сводки в кэш отдельным ключом вида
+// <путь сводки>/YYYYMMDD, чтобы дата извлекалась так же, как из имени файла
+func (a *Archiver) loadRollup(source, path string) error {
+	rollup, err := readRollup(path)
+	if err != nil {
+		return err
+	}
+
+	for day, data := range rollup.Days {
+		a.cache.Set(cache.Key(source, path+"/"+day), data)
+	}
+	fmt.Printf("Loaded rollup: %s/%s (%d days)\n", source, filepath.Base(path), len(rollup.Days))
+	return nil
+}
+
+// expired - день старше keep_days и должен храниться только в сводке
+func (a *Archiver) expired(date time.Time) bool {
+	if a.keepDays <= 0 {
+		return false
+	}
+	now := a.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return date.Before(today.AddDate(0, 0, -a.keepDays))
+}
+
+func (a *Archiver) rollupPath(source string, date time.Time) string {
+	return filepath.Join(a.Dir(source), date.Format("200601")+rollupSuffix)
+}
+
+// fileDate разбирает имя вида YYYYMMDD.<расширение>
+func fileDate(name string) (time.Time, bool) {
+	base, ok := converter.TrimExtension(name)
+	if !ok || len(base) != 8 {
+		return time.Time{}, false
+	}
+	date, err := time.Parse("20060102", base)
+	return date, err == nil
+}
+
+func dayKey(date time.Time) string {
+	return date.Format("20060102")
+}
+
+func isRollupName(name string) bool {
+	month := strings.TrimSuffix(name, rollupSuffix)
+	if month == name {
+		month = strings.TrimSuffix(name, legacyRollupSuffix)
+	}
+	if month == name || len(month) != 6 {
+		return false
+	}
+	_, err := time.Parse("200601", month)
+	return err == nil
+}
+
+// copyFile атомарно копирует файл через временный файл и rename, сохраняя mtime
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime())
+	return os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,242 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/config"
+	"nlbw-ui/internal/converter"
+	"nlbw-ui/internal/demo"
+)
+
+const dayJSON = `{"columns":["family","proto","port","mac","ip","conns","rx_bytes","rx_pkts","tx_bytes","tx_pkts","layer7"],"data":[` +
+	`[4,"TCP",443,"aa:bb:cc:dd:ee:01","192.168.1.10",10,3000,20,1000,10,null],` +
+	`[4,"TCP",80,"aa:bb:cc:dd:ee:01","192.168.1.10",5,2000,10,500,5,null],` +
+	`[4,"UDP",53,"aa:bb:cc:dd:ee:02","192.168.1.20",4,100,4,50,4,null]]}`
+
+func writeDay(t *testing.T, dir, day string) string {
+	t.Helper()
+	path := filepath.Join(dir, day+".json")
+	if err := os.WriteFile(path, []byte(dayJSON), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func newTestArchiver(t *testing.T, keepDays int) (*Archiver, *cache.Cache) {
+	t.Helper()
+	c := cache.New()
+	a := New(config.ArchiveConfig{Dir: t.TempDir(), KeepDays: keepDays}, c)
+	a.now = func() time.Time { return time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC) }
+	return a, c
+}
+
+func TestArchiver_ArchiveAndRetain(t *testing.T) {
+	a, c := newTestArchiver(t, 30)
+	dataDir := t.TempDir()
+
+	// Два дня старше keep_days и один свежий
+	for _, day := range []string{"20240101", "20240102", "20240310"} {
+		if err := a.Archive("main", writeDay(t, dataDir, day)); err != nil {
+			t.Fatalf("Archive failed: %v", err)
+		}
+	}
+
+	if err := a.Retain("main"); err != nil {
+		t.Fatalf("Retain failed: %v", err)
+	}
+
+	dir := a.Dir("main")
+	if _, err := os.Stat(filepath.Join(dir, "20240101.json")); !os.IsNotExist(err) {
+		t.Error("Expired raw file must be removed after rollup")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20240310.json")); err != nil {
+		t.Errorf("Recent raw file must be kept: %v", err)
+	}
+
+	rollup, err := readRollup(filepath.Join(dir, "202401"+rollupSuffix))
+	if err != nil {
+		t.Fatalf("Failed to read rollup: %v", err)
+	}
+	day := rollup.Days["20240101"]
+	if day == nil || len(rollup.Days) != 2 {
+		t.Fatalf("Unexpected rollup days: %v", rollup.Days)
+	}
+
+	// Мелкие TCP 443 + TCP 80 одного устройства сливаются в одну строку
+	if len(day.Data) != 2 {
+		t.Fatalf("Expected 2 downsampled rows, got %d", len(day.Data))
+	}
+	if row := day.Data[0]; row[1] != "TCP" || row[2] != uint16(0) || row[4] != "192.168.1.10" || row[6] != uint64(5000) {
+		t.Errorf("Unexpected merged TCP row: %v", row)
+	}
+
+	// Повторная архивация уже свёрнутого дня не создаёт исходник заново
+	if err := a.Archive("main", filepath.Join(dataDir, "20240101.json")); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20240101.json")); !os.IsNotExist(err) {
+		t.Error("Rolled up day must not be archived again")
+	}
+
+	// Сводка загружена в кэш по дню на ключ
+	if _, ok := c.Get(cache.Key("main", filepath.Join(dir, "202401"+rollupSuffix)+"/20240102")); !ok {
+		t.Error("Rollup days must be loaded into cache")
+	}
+}
+
+func TestArchiver_LoadSkipsLiveDays(t *testing.T) {
+	a, c := newTestArchiver(t, 0)
+	dataDir := t.TempDir()
+
+	for _, day := range []string{"20240101", "20240102"} {
+		if err := a.Archive("main", writeDay(t, dataDir, day)); err != nil {
+			t.Fatalf("Archive failed: %v", err)
+		}
+	}
+
+	skip := func(day string) bool { return day == "20240102" }
+	if err := a.Load("main", skip); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	all := c.GetAll()
+	if len(all) != 1 {
+		t.Fatalf("Expected 1 archived file in cache, got %d", len(all))
+	}
+	for key := range all {
+		if !strings.HasSuffix(key, "20240101.json") {
+			t.Errorf("Unexpected cache key: %s", key)
+		}
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	row := func(proto string, port uint16, ip string, rx uint64) []interface{} {
+		return []interface{}{4, proto, port, "aa:bb:cc:dd:ee:01", ip, uint64(1), rx, uint64(1), uint64(10), uint64(1), nil}
+	}
+	data := &converter.TrafficData{Data: [][]interface{}{
+		row("TCP", 443, "192.168.1.10", 3<<20),
+		row("TCP", 443, "192.168.1.11", 2<<20),
+		row("TCP", 22, "192.168.1.11", 1000),
+		row("TCP", 8080, "192.168.1.12", 500),
+		row("UDP", 53, "192.168.1.10", 300),
+	}}
+
+	result := Downsample(data)
+
+	// Заметный трафик остаётся с портом и адресом, мелочь - по строке на протокол
+	if len(result.Data) != 4 {
+		t.Fatalf("Expected 4 rows, got %d: %v", len(result.Data), result.Data)
+	}
+	if first := result.Data[0]; first[2] != uint16(443) || first[4] != "192.168.1.10" || first[6] != uint64(3<<20) {
+		t.Errorf("Expected large row to keep port and address, got %v", first)
+	}
+	var small []interface{}
+	for _, r := range result.Data {
+		if r[1] == "TCP" && r[2] == uint16(0) {
+			small = r
+		}
+	}
+	if small == nil || small[4] != "192.168.1.11" || small[6] != uint64(1500) || small[5] != uint64(2) {
+		t.Errorf("Expected small TCP rows merged under the larger one's IP, got %v", small)
+	}
+
+	var rx uint64
+	for _, r := range result.Data {
+		rx += r[6].(uint64)
+	}
+	if rx != 5<<20+1800 {
+		t.Errorf("Downsampling must keep total traffic, got %d", rx)
+	}
+}
+
+func TestRetain_RollupSmallerThanRawFiles(t *testing.T) {
+	a, c := newTestArchiver(t, 30)
+	dataDir := t.TempDir()
+	generator := demo.NewGeneratorWithSeed(3, nil)
+
+	var rawSize int64
+	var rawRx uint64
+	for day := 1; day <= 10; day++ {
+		date := time.Date(2024, 1, day, 0, 0, 0, 0, time.Local)
+		data := generator.GenerateForDate(date)
+		if err := demo.WriteDay(dataDir, date, data); err != nil {
+			t.Fatalf("WriteDay failed: %v", err)
+		}
+		path := filepath.Join(dataDir, demo.FormatDateForFilename(date)+".db.gz")
+		if err := a.Archive("main", path); err != nil {
+			t.Fatalf("Archive failed: %v", err)
+		}
+		info, _ := os.Stat(path)
+		rawSize += info.Size()
+		for _, row := range data.Data {
+			rawRx += row[6].(uint64)
+		}
+	}
+
+	if err := a.Retain("main"); err != nil {
+		t.Fatalf("Retain failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(a.Dir("main"), "202401"+rollupSuffix))
+	if err != nil {
+		t.Fatalf("Rollup not written: %v", err)
+	}
+	if info.Size() >= rawSize {
+		t.Errorf("Rollup (%d bytes) must be smaller than the raw files (%d bytes)", info.Size(), rawSize)
+	}
+
+	var rx uint64
+	for _, data := range c.GetAll() {
+		for _, row := range data.Data {
+			rx += row[6].(uint64)
+		}
+	}
+	if rx != rawRx {
+		t.Errorf("Expected %d bytes downloaded after rollup, got %d", rawRx, rx)
+	}
+}
+
+func TestRetain_MigratesLegacyRollup(t *testing.T) {
+	a, c := newTestArchiver(t, 30)
+	dir := a.Dir("main")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Несжатая сводка прежней версии
+	legacyPath := filepath.Join(dir, "202401"+legacyRollupSuffix)
+	legacy := `{"month":"202401","days":{"20240101":` + dayJSON + `}}`
+	if err := os.WriteFile(legacyPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Load("main", nil); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := c.Get(cache.Key("main", legacyPath+"/20240101")); !ok {
+		t.Fatal("Legacy rollup must be loaded")
+	}
+
+	if err := a.Archive("main", writeDay(t, t.TempDir(), "20240102")); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if err := a.Retain("main"); err != nil {
+		t.Fatalf("Retain failed: %v", err)
+	}
+
+	rollup, err := readRollup(filepath.Join(dir, "202401"+rollupSuffix))
+	if err != nil || len(rollup.Days) != 2 {
+		t.Fatalf("Expected both days in the compressed rollup, got %v (%v)", rollup, err)
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Error("Legacy rollup must be removed after migration")
+	}
+	if len(c.GetAll()) != 2 {
+		t.Errorf("Expected 2 cached days without duplicates, got %d", len(c.GetAll()))
+	}
+}
@@ -0,0 +1,180 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"nlbw-ui/internal/converter"
+)
+
+// rollupSuffix - расширение помесячной сводки (JSON, сжатый gzip)
+const rollupSuffix = ".rollup.json.gz"
+
+// legacyRollupSuffix - несжатые сводки прежних версий; читаются и
+// заменяются сжатыми при следующей записи того же месяца
+const legacyRollupSuffix = ".rollup.json"
+
+// rollupMinRowBytes - строки с меньшим трафиком (rx+tx) сводка
+// не хранит по отдельности
+const rollupMinRowBytes = 1 << 20
+
+// Rollup - помесячная сводка: по дню на ключ YYYYMMDD,
+// каждый день в формате вывода `nlbw -c json`
+type Rollup struct {
+	Month string                            `json:"month"` // YYYYMM
+	Days  map[string]*converter.TrafficData `json:"days"`
+}
+
+// Downsample уменьшает детализацию дня: строки от rollupMinRowBytes
+// переносятся как есть, более мелкие суммируются в одну строку на
+// family/proto/mac с нулевым портом и самым крупным IP устройства среди них.
+// Трафик устройств и протоколов сохраняется точно, порты и адреса -
+// для заметного трафика; мелочь (DNS, NTP, фоновые соединения), которая
+// и составляет большую часть строк, теряет детализацию.
+func Downsample(data *converter.TrafficData) *converter.TrafficData {
+	type rowKey struct {
+		family interface{}
+		proto  string
+		port   uint16
+		mac    string
+		ip     string
+	}
+
+	// Мелкие строки сливаются от крупных к мелким, чтобы IP был самым заметным
+	sorted := make([][]interface{}, 0, len(data.Data))
+	for _, row := range data.Data {
+		if len(row) >= 10 {
+			sorted = append(sorted, row)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rowBytes(sorted[i]) > rowBytes(sorted[j])
+	})
+
+	rows := make(map[rowKey][]interface{})
+	var order []rowKey
+
+	for _, row := range sorted {
+		proto, _ := row[1].(string)
+		port, _ := row[2].(uint16)
+		mac, _ := row[3].(string)
+		ip, _ := row[4].(string)
+		key := rowKey{row[0], proto, port, mac, ip}
+
+		var layer7 interface{}
+		if len(row) > 10 {
+			layer7 = row[10]
+		}
+		if rowBytes(row) < rollupMinRowBytes {
+			key = rowKey{family: row[0], proto: proto, mac: mac}
+			layer7 = nil
+		}
+
+		merged, exists := rows[key]
+		if !exists {
+			merged = []interface{}{row[0], proto, key.port, mac, ip,
+				uint64(0), uint64(0), uint64(0), uint64(0), uint64(0), layer7}
+			rows[key] = merged
+			order = append(order, key)
+		}
+		for i := 5; i <= 9; i++ {
+			value, _ := row[i].(uint64)
+			merged[i] = merged[i].(uint64) + value
+		}
+	}
+
+	result := &converter.TrafficData{
+		Columns: converter.Columns,
+		Data:    make([][]interface{}, 0, len(order)),
+	}
+	for _, key := range order {
+		result.Data = append(result.Data, rows[key])
+	}
+
+	// Как и у исходных файлов - по убыванию скачанного
+	sort.SliceStable(result.Data, func(i, j int) bool {
+		return result.Data[i][6].(uint64) > result.Data[j][6].(uint64)
+	})
+
+	return result
+}
+
+// rowBytes - трафик строки в обе стороны
+func rowBytes(row []interface{}) uint64 {
+	rx, _ := row[6].(uint64)
+	tx, _ := row[8].(uint64)
+	return rx + tx
+}
+
+// readRollup читает сводку (сжатую или прежнюю несжатую);
+// значения приводятся к типам converter через ParseJSON
+func readRollup(path string) (*Rollup, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+
+	var raw struct {
+		Month string                     `json:"month"`
+		Days  map[string]json.RawMessage `json:"days"`
+	}
+	if err := json.NewDecoder(reader).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	rollup := &Rollup{Month: raw.Month, Days: make(map[string]*converter.TrafficData, len(raw.Days))}
+	for day, dayData := range raw.Days {
+		data, err := converter.ParseJSON(bytes.NewReader(dayData))
+		if err != nil {
+			return nil, err
+		}
+		rollup.Days[day] = data
+	}
+
+	return rollup, nil
+}
+
+// writeRollup атомарно записывает сводку, сжимая её gzip
+func writeRollup(path string, rollup *Rollup) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	gzWriter := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(gzWriter).Encode(rollup); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := gzWriter.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
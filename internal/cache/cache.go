@@ -1,30 +1,124 @@
 package cache
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"nlbw-ui/internal/converter"
 )
 
+// keySeparator отделяет имя источника от пути файла в ключе кэша.
+// Имена источников не могут содержать ':' (проверяется в config).
+const keySeparator = ":"
+
+// Key формирует ключ кэша для файла источника
+func Key(source, path string) string {
+	return source + keySeparator + path
+}
+
+// SplitKey разбирает ключ кэша на источник и путь файла
+func SplitKey(key string) (source, path string) {
+	if i := strings.Index(key, keySeparator); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// SetHook вызывается после каждого Set.
+// existed = true, если данные по этому пути уже были в кэше.
+type SetHook func(path string, data *converter.TrafficData, existed bool)
+
+// LoadMerger объединяет прочитанный LoadFile файл с данными, уже лежащими в кэше
+// под тем же ключом. ok = false - оставить кэш как есть; nil merged - сохранить
+// прочитанное без слияния.
+type LoadMerger func(key string, cached, loaded *converter.TrafficData) (merged *converter.TrafficData, ok bool)
+
 type Cache struct {
 	data      map[string]*converter.TrafficData
+	dates     map[string]map[string]bool // дата -> ключи, для GetDate
 	mu        sync.RWMutex
 	converter *converter.Converter
+
+	hooksMu sync.RWMutex
+	onSet   []SetHook
+	merger  LoadMerger
+
+	statusMu    sync.RWMutex
+	statuses    map[string]*FileStatus // по ключу кэша, только для LoadFile
+	retries     map[string]*time.Timer // запланированные повторы LoadFile
+	retryDelays []time.Duration
 }
 
 func New() *Cache {
 	return &Cache{
-		data:      make(map[string]*converter.TrafficData),
-		converter: converter.New(),
+		data:        make(map[string]*converter.TrafficData),
+		dates:       make(map[string]map[string]bool),
+		converter:   converter.New(),
+		statuses:    make(map[string]*FileStatus),
+		retries:     make(map[string]*time.Timer),
+		retryDelays: defaultRetryDelays,
 	}
 }
 
 func (c *Cache) Set(path string, data *converter.TrafficData) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	_, existed := c.data[path]
 	c.data[path] = data
+	date := converter.DateFromFilename(path)
+	if c.dates[date] == nil {
+		c.dates[date] = make(map[string]bool)
+	}
+	c.dates[date][path] = true
+	c.mu.Unlock()
+
+	// Хуки вызываются вне блокировки, чтобы они могли читать кэш
+	c.hooksMu.RLock()
+	hooks := c.onSet
+	c.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(path, data, existed)
+	}
+}
+
+// OnSet добавляет обработчик, вызываемый после каждого Set
+func (c *Cache) OnSet(hook SetHook) {
+	c.hooksMu.Lock()
+	defer c.hooksMu.Unlock()
+	c.onSet = append(c.onSet, hook)
+}
+
+// SetLoadMerger задаёт слияние для LoadFile (см. LoadMerger)
+func (c *Cache) SetLoadMerger(merger LoadMerger) {
+	c.hooksMu.Lock()
+	defer c.hooksMu.Unlock()
+	c.merger = merger
+}
+
+// store кладёт прочитанный LoadFile файл в кэш с учётом LoadMerger
+func (c *Cache) store(key string, loaded *converter.TrafficData) {
+	c.hooksMu.RLock()
+	merger := c.merger
+	c.hooksMu.RUnlock()
+
+	if merger != nil {
+		cached, _ := c.Get(key)
+		merged, ok := merger(key, cached, loaded)
+		if !ok {
+			return
+		}
+		if merged != nil {
+			loaded = merged
+		}
+	}
+	c.Set(key, loaded)
 }
 
 func (c *Cache) Get(path string) (*converter.TrafficData, bool) {
@@ -34,14 +128,155 @@ func (c *Cache) Get(path string) (*converter.TrafficData, bool) {
 	return data, ok
 }
 
-func (c *Cache) LoadFile(path string) error {
-	data, err := c.converter.ConvertFile(path)
+// GetDate возвращает файлы всех источников за дату YYYY-MM-DD
+func (c *Cache) GetDate(date string) map[string]*converter.TrafficData {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]*converter.TrafficData, len(c.dates[date]))
+	for key := range c.dates[date] {
+		result[key] = c.data[key]
+	}
+	return result
+}
+
+// Delete удаляет файл из кэша
+func (c *Cache) Delete(path string) {
+	c.mu.Lock()
+	c.deleteLocked(path)
+	c.mu.Unlock()
+
+	c.deleteStatuses(func(key string) bool { return key == path })
+}
+
+// ClearSource удаляет из кэша все файлы источника (например, при смене его data_dir)
+func (c *Cache) ClearSource(source string) {
+	inSource := func(key string) bool {
+		keySource, _ := SplitKey(key)
+		return keySource == source
+	}
+
+	c.mu.Lock()
+	for key := range c.data {
+		if inSource(key) {
+			c.deleteLocked(key)
+		}
+	}
+	c.mu.Unlock()
+
+	c.deleteStatuses(inSource)
+}
+
+// deleteLocked удаляет файл и его запись в индексе дат, c.mu должен быть захвачен
+func (c *Cache) deleteLocked(path string) {
+	delete(c.data, path)
+
+	date := converter.DateFromFilename(path)
+	delete(c.dates[date], path)
+	if len(c.dates[date]) == 0 {
+		delete(c.dates, date)
+	}
+}
+
+// Sources возвращает отсортированный список источников, для которых есть данные
+func (c *Cache) Sources() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for key := range c.data {
+		source, _ := SplitKey(key)
+		seen[source] = true
+	}
+
+	sources := make([]string, 0, len(seen))
+	for source := range seen {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// LoadFile конвертирует файл и кладёт его в кэш под ключом Key(source, path).
+// Неудачное чтение повторяется с паузами - nlbwmon мог как раз перезаписывать файл.
+// Повторы идут в фоне по таймеру, а не в вызывающей горутине, чтобы не держать
+// блокировки сканера; пока они не закончились, статус файла - retrying.
+// Обрезанный файл загружается частично (статус truncated), нечитаемый
+// попадает в карантин и пропускается, пока не изменится; прежние данные
+// файла в кэше при этом сохраняются. Итог записывается в FileStatuses.
+func (c *Cache) LoadFile(source, path string) error {
+	c.cancelRetry(Key(source, path))
+	return c.loadFile(source, path, 1)
+}
+
+// loadFile делает попытку attempt и при неудаче планирует следующую
+func (c *Cache) loadFile(source, path string, attempt int) error {
+	key := Key(source, path)
+
+	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("failed to convert file: %w", err)
+		c.setStatus(key, &FileStatus{Status: StatusError, Error: err.Error(), Attempts: attempt})
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if attempt == 1 && c.quarantined(key, info) {
+		return ErrQuarantined
+	}
+
+	data, err := c.converter.ConvertFile(path)
+	retry := err != nil && !errors.Is(err, fs.ErrNotExist) && attempt <= len(c.retryDelays)
+
+	status := &FileStatus{
+		Attempts: attempt,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	}
+
+	var truncated *converter.TruncatedError
+	switch {
+	case err == nil:
+		status.Status = StatusOK
+		status.Records = len(data.Data)
+		c.store(key, data)
+		fmt.Printf("Loaded and cached: %s/%s\n", source, filepath.Base(path))
+
+	case errors.As(err, &truncated) && data != nil:
+		// Частичные данные лучше, чем ничего, даже если будет ещё попытка
+		status.Status = StatusTruncated
+		status.Records = len(data.Data)
+		status.Expected = int(truncated.Expected)
+		status.Error = err.Error()
+		c.store(key, data)
+		fmt.Printf("Warning: %s/%s is truncated, loaded %d of %d records\n",
+			source, filepath.Base(path), truncated.Read, truncated.Expected)
+
+	case errors.Is(err, fs.ErrNotExist):
+		status.Status = StatusError
+		status.Error = err.Error()
+
+	case retry:
+		status.Status = StatusRetrying
+		status.Error = err.Error()
+
+	default:
+		status.Status = StatusQuarantined
+		status.Error = err.Error()
+		fmt.Printf("Quarantined %s/%s: %v\n", source, filepath.Base(path), err)
 	}
 
-	c.Set(path, data)
-	fmt.Printf("Loaded and cached: %s\n", filepath.Base(path))
+	c.setStatus(key, status)
+
+	if retry {
+		delay := c.retryDelays[attempt-1]
+		fmt.Printf("Failed to read %s/%s, retrying in %s: %v\n", source, filepath.Base(path), delay, err)
+		c.scheduleRetry(source, path, attempt+1, delay)
+	}
+
+	switch status.Status {
+	case StatusError, StatusQuarantined:
+		return fmt.Errorf("failed to convert file: %w", err)
+	case StatusRetrying:
+		return fmt.Errorf("failed to convert file, will retry: %w", err)
+	}
 	return nil
 }
 
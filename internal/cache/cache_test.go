@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nlbw-ui/internal/converter"
+)
+
+// writeDatabase пишет .db.gz, в заголовке которого entries записей,
+// а на деле записано только written
+func writeDatabase(t *testing.T, path string, entries, written uint32) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	binary.Write(gz, binary.BigEndian, &converter.Database{Magic: converter.Magic, Entries: entries})
+	for i := uint32(0); i < written; i++ {
+		record := converter.Record{Family: converter.AF_INET, Proto: 6, DstPort: 443, InBytes: uint64(i+1) * 1000}
+		record.SrcMAC = [8]byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, byte(i)}
+		binary.Write(gz, binary.BigEndian, &record)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func newTestCache() *Cache {
+	c := New()
+	c.retryDelays = []time.Duration{time.Millisecond}
+	return c
+}
+
+// waitStatus ждёт, пока статус единственного файла не будет удовлетворять done
+func waitStatus(t *testing.T, c *Cache, done func(FileStatus) bool) FileStatus {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		statuses := c.FileStatuses()
+		if len(statuses) == 1 && done(statuses[0]) {
+			return statuses[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for file status, got %+v", statuses)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLoadFile_Truncated(t *testing.T) {
+	c := newTestCache()
+	path := filepath.Join(t.TempDir(), "20240101.db.gz")
+	writeDatabase(t, path, 5, 3)
+
+	if err := c.LoadFile("main", path); err != nil {
+		t.Fatalf("Truncated file must load partially, got %v", err)
+	}
+
+	data, ok := c.Get(Key("main", path))
+	if !ok || len(data.Data) != 3 {
+		t.Fatalf("Expected 3 recovered records, got %v", data)
+	}
+
+	// Повтор идёт в фоне и снова находит обрезанный файл
+	status := waitStatus(t, c, func(s FileStatus) bool { return s.Attempts == 2 })
+	if status.Status != StatusTruncated || status.Records != 3 || status.Expected != 5 || status.Attempts != 2 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestLoadFile_Quarantine(t *testing.T) {
+	c := newTestCache()
+	path := filepath.Join(t.TempDir(), "20240101.db.gz")
+
+	// Сначала нормальный файл, затем он портится
+	writeDatabase(t, path, 2, 2)
+	if err := c.LoadFile("main", path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("not a database"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadFile("main", path); err == nil {
+		t.Fatal("Expected error for corrupt file")
+	}
+	if status := c.FileStatuses()[0]; status.Status != StatusRetrying {
+		t.Errorf("Expected retrying status after the first attempt, got %+v", status)
+	}
+
+	status := waitStatus(t, c, func(s FileStatus) bool { return s.Status != StatusRetrying })
+	if status.Status != StatusQuarantined || status.Error == "" || status.Attempts != 2 {
+		t.Errorf("Expected quarantined status, got %+v", status)
+	}
+
+	// Прежние данные остаются в кэше
+	if data, ok := c.Get(Key("main", path)); !ok || len(data.Data) != 2 {
+		t.Error("Previous data must be kept for a quarantined file")
+	}
+
+	// Неизменённый файл больше не читается
+	if err := c.LoadFile("main", path); !errors.Is(err, ErrQuarantined) {
+		t.Errorf("Expected ErrQuarantined, got %v", err)
+	}
+
+	// Изменившийся файл читается снова
+	writeDatabase(t, path, 4, 4)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if err := c.LoadFile("main", path); err != nil {
+		t.Fatalf("Changed file must be reloaded: %v", err)
+	}
+	if status := c.FileStatuses()[0]; status.Status != StatusOK || status.Records != 4 {
+		t.Errorf("Unexpected status after fix: %+v", status)
+	}
+}
+
+func TestGetDate(t *testing.T) {
+	c := New()
+	c.Set(Key("main", "/data/20240101.db.gz"), &converter.TrafficData{})
+	c.Set(Key("main", "/archive/20240101.db.gz"), &converter.TrafficData{})
+	c.Set(Key("office", "/office/20240101.json"), &converter.TrafficData{})
+	c.Set(Key("main", "/data/20240102.db.gz"), &converter.TrafficData{})
+
+	if day := c.GetDate("2024-01-01"); len(day) != 3 {
+		t.Errorf("Expected 3 files for 2024-01-01, got %d", len(day))
+	}
+
+	c.Delete(Key("main", "/archive/20240101.db.gz"))
+	c.ClearSource("office")
+	day := c.GetDate("2024-01-01")
+	if _, ok := day[Key("main", "/data/20240101.db.gz")]; len(day) != 1 || !ok {
+		t.Errorf("Unexpected files after delete: %v", day)
+	}
+
+	c.Delete(Key("main", "/data/20240102.db.gz"))
+	if len(c.GetDate("2024-01-02")) != 0 || len(c.dates) != 1 {
+		t.Errorf("Empty dates must be removed from the index: %v", c.dates)
+	}
+}
+
+func TestLoadFile_RetryInBackground(t *testing.T) {
+	c := New()
+	c.retryDelays = []time.Duration{20 * time.Millisecond}
+	path := filepath.Join(t.TempDir(), "20240101.db.gz")
+
+	// nlbwmon ещё пишет файл: первая попытка не удаётся, повтор уже видит базу
+	if err := os.WriteFile(path, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadFile("main", path); err == nil {
+		t.Fatal("Expected error for unreadable file")
+	}
+	writeDatabase(t, path, 2, 2)
+
+	status := waitStatus(t, c, func(s FileStatus) bool { return s.Status != StatusRetrying })
+	if status.Status != StatusOK || status.Attempts != 2 {
+		t.Errorf("Expected the retry to load the file, got %+v", status)
+	}
+	if _, ok := c.Get(Key("main", path)); !ok {
+		t.Error("Retried file must be cached")
+	}
+
+	// Удаление источника отменяет запланированный повтор
+	os.WriteFile(path, []byte("partial"), 0644)
+	c.LoadFile("main", path)
+	writeDatabase(t, path, 2, 2)
+	c.ClearSource("main")
+
+	time.Sleep(3 * c.retryDelays[0])
+	if _, ok := c.Get(Key("main", path)); ok || len(c.FileStatuses()) != 0 {
+		t.Error("Retry must not reload a file of a cleared source")
+	}
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Статусы загрузки файла
+const (
+	StatusOK          = "ok"
+	StatusTruncated   = "truncated"   // загружена только часть записей
+	StatusError       = "error"       // файл не удалось прочитать
+	StatusRetrying    = "retrying"    // чтение не удалось, повтор запланирован
+	StatusQuarantined = "quarantined" // не читается после повторов, пропускается до изменения
+)
+
+// ErrQuarantined - файл в карантине и не менялся с последней неудачной загрузки
+var ErrQuarantined = errors.New("file is quarantined until it changes")
+
+// defaultRetryDelays - паузы между повторными попытками чтения файла,
+// который nlbwmon может как раз перезаписывать
+var defaultRetryDelays = []time.Duration{200 * time.Millisecond, 500 * time.Millisecond, time.Second}
+
+// FileStatus - результат последней загрузки файла
+type FileStatus struct {
+	Source    string    `json:"source"`
+	File      string    `json:"file"`
+	Path      string    `json:"path"`
+	Status    string    `json:"status"`
+	Records   int       `json:"records"`
+	Expected  int       `json:"expected_records,omitempty"` // записей по заголовку, если файл обрезан
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FileStatuses возвращает статусы загрузки всех файлов, отсортированные по источнику и имени
+func (c *Cache) FileStatuses() []FileStatus {
+	c.statusMu.RLock()
+	defer c.statusMu.RUnlock()
+
+	result := make([]FileStatus, 0, len(c.statuses))
+	for _, status := range c.statuses {
+		result = append(result, *status)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Source != result[j].Source {
+			return result[i].Source < result[j].Source
+		}
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// quarantined проверяет, что файл в карантине и с тех пор не менялся
+func (c *Cache) quarantined(key string, info os.FileInfo) bool {
+	c.statusMu.RLock()
+	defer c.statusMu.RUnlock()
+
+	status, ok := c.statuses[key]
+	return ok && status.Status == StatusQuarantined &&
+		status.Size == info.Size() && status.ModTime.Equal(info.ModTime())
+}
+
+func (c *Cache) setStatus(key string, status *FileStatus) {
+	source, path := SplitKey(key)
+	status.Source = source
+	status.Path = path
+	status.File = filepath.Base(path)
+	status.UpdatedAt = time.Now()
+
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	c.statuses[key] = status
+}
+
+// deleteStatuses удаляет статусы и отменяет запланированные повторы
+// подходящих файлов, чтобы повтор не вернул в кэш удалённые данные
+func (c *Cache) deleteStatuses(match func(key string) bool) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	for key := range c.statuses {
+		if match(key) {
+			delete(c.statuses, key)
+		}
+	}
+	for key, timer := range c.retries {
+		if match(key) {
+			timer.Stop()
+			delete(c.retries, key)
+		}
+	}
+}
+
+// scheduleRetry планирует попытку attempt загрузки файла через delay.
+// Новый LoadFile того же файла или его удаление из кэша отменяют повтор.
+func (c *Cache) scheduleRetry(source, path string, attempt int, delay time.Duration) {
+	key := Key(source, path)
+
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	if previous, ok := c.retries[key]; ok {
+		previous.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		c.statusMu.Lock()
+		current := c.retries[key] == timer
+		if current {
+			delete(c.retries, key)
+		}
+		c.statusMu.Unlock()
+
+		if current {
+			c.loadFile(source, path, attempt)
+		}
+	})
+	c.retries[key] = timer
+}
+
+// cancelRetry отменяет запланированный повтор загрузки файла
+func (c *Cache) cancelRetry(key string) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	if timer, ok := c.retries[key]; ok {
+		timer.Stop()
+		delete(c.retries, key)
+	}
+}
@@ -2,20 +2,101 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"nlbw-ui/internal/services"
 )
 
 type Config struct {
 	DataDir       string            `yaml:"data_dir"`
+	Sources       []SourceConfig    `yaml:"sources"`
 	ServerAddress string            `yaml:"server_address"`
 	ServerPort    int               `yaml:"server_port"`
 	FriendlyNames map[string]string `yaml:"friendly_names"`
+	Live          LiveConfig        `yaml:"live"`
+	Archive       ArchiveConfig     `yaml:"archive"`
+	AdminToken    string            `yaml:"admin_token"` // Bearer токен для загрузки файлов и перезагрузки конфига через API
+	Categories    []CategoryRule    `yaml:"categories"`  // дополнительные правила классификации трафика
+	Segments      []SegmentConfig   `yaml:"segments"`    // именованные сегменты сети (lan, guest, iot)
+
+	// Warnings - замечания к конфигу, не мешающие работе; выводятся вызывающим
+	Warnings []string `yaml:"-"`
+}
+
+// SegmentConfig - именованный сегмент сети: подсети и/или список устройств.
+// Запись относится к сегменту по MAC, затем по самой узкой подходящей подсети.
+type SegmentConfig struct {
+	Name  string   `yaml:"name"`
+	CIDRs []string `yaml:"cidrs"` // 192.168.2.0/24, fd00:2::/64
+	MACs  []string `yaml:"macs"`  // устройства сегмента независимо от адреса
+}
+
+// SegmentOther - сегмент записей, не попавших ни в один из настроенных
+const SegmentOther = "other"
+
+// CategoryRule относит протокол и порты к категории приложений.
+// Правила проверяются по порядку до встроенной таблицы сервисов.
+type CategoryRule struct {
+	Category string   `yaml:"category"`
+	Proto    string   `yaml:"proto"` // tcp, udp, icmp или any (по умолчанию)
+	Ports    []string `yaml:"ports"` // порты и диапазоны: 443, 6881-6889
 }
 
+// SourceConfig - один роутер/точка доступа со своим nlbwmon
+type SourceConfig struct {
+	Name    string `yaml:"name"`
+	Type    string `yaml:"type"`     // local (по умолчанию) или http
+	DataDir string `yaml:"data_dir"` // для http - локальная директория-зеркало
+
+	// Только для type: http
+	URL      string        `yaml:"url"`      // адрес списка файлов, файлы - url/<имя>
+	Interval time.Duration `yaml:"interval"` // период синхронизации
+}
+
+// Типы источников
+const (
+	SourceLocal = "local"
+	SourceHTTP  = "http"
+)
+
+// DefaultSourceName - имя источника, если в конфиге указан только data_dir
+const DefaultSourceName = "default"
+
+// LiveConfig - опрос работающего nlbwmon для данных за сегодня,
+// которые ещё не сброшены на диск (commit_interval)
+type LiveConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Socket   string        `yaml:"socket"`   // управляющий сокет nlbwmon
+	Command  string        `yaml:"command"`  // альтернатива сокету, например "nlbw -c json"
+	Interval time.Duration `yaml:"interval"` // период опроса
+	Source   string        `yaml:"source"`   // источник, к которому относится nlbwmon (по умолчанию первый)
+}
+
+// ArchiveConfig - долговременное хранение баз, которые nlbwmon удаляет
+// по настройке generations
+type ArchiveConfig struct {
+	Dir      string `yaml:"dir"`       // пусто = архив отключён
+	KeepDays int    `yaml:"keep_days"` // сколько дней хранить исходные файлы, 0 = всегда
+}
+
+// Enabled - включён ли архив
+func (a ArchiveConfig) Enabled() bool {
+	return a.Dir != ""
+}
+
+const (
+	defaultLiveSocket   = "/var/run/nlbwmon.sock"
+	defaultLiveInterval = 30 * time.Second
+
+	defaultRemoteInterval = 5 * time.Minute
+)
+
 const defaultConfig = `# NLBW Monitor Configuration
 # Directory containing *.db.gz files
 data_dir: ./data
@@ -49,13 +130,27 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
+	cfg.applyDefaults()
+
 	if err := cfg.validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
+	cfg.Warnings = cfg.warnings()
 
-	cfg.DataDir, err = filepath.Abs(cfg.DataDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve data_dir path: %w", err)
+	for i := range cfg.Sources {
+		cfg.Sources[i].DataDir, err = filepath.Abs(cfg.Sources[i].DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve data_dir path for source %s: %w", cfg.Sources[i].Name, err)
+		}
+	}
+	// data_dir указывает на первый источник - для совместимости
+	cfg.DataDir = cfg.Sources[0].DataDir
+
+	if cfg.Archive.Enabled() {
+		cfg.Archive.Dir, err = filepath.Abs(cfg.Archive.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve archive dir path: %w", err)
+		}
 	}
 
 	// Normalize MAC addresses in friendly_names to lowercase
@@ -75,18 +170,216 @@ func createDefaultConfig(path string) error {
 	return os.WriteFile(path, []byte(defaultConfig), 0644)
 }
 
+// applyDefaults заполняет необязательные параметры значениями по умолчанию
+func (c *Config) applyDefaults() {
+	// Старый формат: один data_dir без sources
+	if len(c.Sources) == 0 && c.DataDir != "" {
+		c.Sources = []SourceConfig{{Name: DefaultSourceName, DataDir: c.DataDir}}
+	}
+
+	for i := range c.Sources {
+		if c.Sources[i].Type == "" {
+			c.Sources[i].Type = SourceLocal
+		}
+		if c.Sources[i].Type == SourceHTTP && c.Sources[i].Interval == 0 {
+			c.Sources[i].Interval = defaultRemoteInterval
+		}
+	}
+
+	for i := range c.Categories {
+		rule := &c.Categories[i]
+		rule.Category = strings.ToLower(strings.TrimSpace(rule.Category))
+		rule.Proto = strings.ToLower(rule.Proto)
+		if rule.Proto == "" {
+			rule.Proto = "any"
+		}
+	}
+
+	for i := range c.Segments {
+		segment := &c.Segments[i]
+		segment.Name = strings.TrimSpace(segment.Name)
+		for j := range segment.MACs {
+			segment.MACs[j] = strings.ToLower(strings.TrimSpace(segment.MACs[j]))
+		}
+	}
+
+	if c.Live.Enabled {
+		if c.Live.Source == "" && len(c.Sources) > 0 {
+			c.Live.Source = c.Sources[0].Name
+		}
+		if c.Live.Socket == "" && c.Live.Command == "" {
+			c.Live.Socket = defaultLiveSocket
+		}
+		if c.Live.Interval == 0 {
+			c.Live.Interval = defaultLiveInterval
+		}
+	}
+}
+
+// warnings собирает замечания к уже проверенному конфигу
+func (c *Config) warnings() []string {
+	var result []string
+
+	// data_dir, заданный вместе с sources, не используется - скорее всего
+	// это остаток старого конфига
+	legacy := SourceConfig{Name: DefaultSourceName, Type: SourceLocal, DataDir: c.DataDir}
+	if c.DataDir != "" && !(len(c.Sources) == 1 && c.Sources[0] == legacy) {
+		result = append(result, fmt.Sprintf("both data_dir and sources are set, data_dir %s is ignored", c.DataDir))
+	}
+
+	return result
+}
+
 func (c *Config) validate() error {
-	if c.DataDir == "" {
+	if c.DataDir == "" && len(c.Sources) == 0 {
 		return fmt.Errorf("data_dir cannot be empty")
 	}
 
+	names := make(map[string]bool, len(c.Sources))
+	for _, src := range c.Sources {
+		if src.Name == "" {
+			return fmt.Errorf("source name cannot be empty")
+		}
+		if strings.ContainsAny(src.Name, ":/,") {
+			return fmt.Errorf("source %s: name must not contain ':', '/' or ','", src.Name)
+		}
+		if names[src.Name] {
+			return fmt.Errorf("duplicate source name: %s", src.Name)
+		}
+		names[src.Name] = true
+
+		if src.DataDir == "" {
+			return fmt.Errorf("source %s: data_dir cannot be empty", src.Name)
+		}
+
+		switch src.Type {
+		case "", SourceLocal:
+		case SourceHTTP:
+			if !strings.HasPrefix(src.URL, "http://") && !strings.HasPrefix(src.URL, "https://") {
+				return fmt.Errorf("source %s: url must start with http:// or https://", src.Name)
+			}
+			if src.Interval < 10*time.Second {
+				return fmt.Errorf("source %s: interval must be at least 10s", src.Name)
+			}
+		default:
+			return fmt.Errorf("source %s: unknown type %q", src.Name, src.Type)
+		}
+	}
+
 	if c.ServerPort <= 0 || c.ServerPort > 65535 {
 		return fmt.Errorf("server_port must be between 1 and 65535")
 	}
 
+	if c.Live.Enabled {
+		if c.Live.Socket != "" && c.Live.Command != "" {
+			return fmt.Errorf("live: socket and command are mutually exclusive")
+		}
+		if c.Live.Interval < time.Second {
+			return fmt.Errorf("live: interval must be at least 1s")
+		}
+		if len(c.Sources) > 0 && !names[c.Live.Source] {
+			return fmt.Errorf("live: unknown source %s", c.Live.Source)
+		}
+	}
+
+	if c.Archive.KeepDays < 0 {
+		return fmt.Errorf("archive: keep_days cannot be negative")
+	}
+
+	if _, err := c.CategoryRules(); err != nil {
+		return err
+	}
+
+	if err := c.validateSegments(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Config) validateSegments() error {
+	names := make(map[string]bool, len(c.Segments))
+	macs := make(map[string]string)
+	for i, segment := range c.Segments {
+		if segment.Name == "" {
+			return fmt.Errorf("segments[%d]: name cannot be empty", i)
+		}
+		if strings.Contains(segment.Name, ",") {
+			return fmt.Errorf("segment %s: name must not contain ','", segment.Name)
+		}
+		if segment.Name == SegmentOther {
+			return fmt.Errorf("segment name %q is reserved for unmatched traffic", SegmentOther)
+		}
+		if names[segment.Name] {
+			return fmt.Errorf("duplicate segment name: %s", segment.Name)
+		}
+		names[segment.Name] = true
+
+		if len(segment.CIDRs) == 0 && len(segment.MACs) == 0 {
+			return fmt.Errorf("segment %s: cidrs or macs required", segment.Name)
+		}
+		for _, cidr := range segment.CIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("segment %s: invalid cidr %q", segment.Name, cidr)
+			}
+		}
+		for j, mac := range segment.MACs {
+			hw, err := net.ParseMAC(mac)
+			if err != nil {
+				return fmt.Errorf("segment %s: invalid mac %q", segment.Name, mac)
+			}
+			// В данных MAC всегда вида aa:bb:cc:dd:ee:ff, а конфиг допускает и aa-bb-...
+			mac = hw.String()
+			c.Segments[i].MACs[j] = mac
+			if other, exists := macs[mac]; exists {
+				return fmt.Errorf("segment %s: mac %s is already in segment %s", segment.Name, mac, other)
+			}
+			macs[mac] = segment.Name
+		}
+	}
 	return nil
 }
 
+// CategoryRules возвращает правила классификации из конфига
+func (c *Config) CategoryRules() ([]services.Rule, error) {
+	var rules []services.Rule
+	for i, rule := range c.Categories {
+		if rule.Category == "" {
+			return nil, fmt.Errorf("categories[%d]: category cannot be empty", i)
+		}
+		if !services.ValidProto(rule.Proto) {
+			return nil, fmt.Errorf("categories[%d]: invalid proto %q", i, rule.Proto)
+		}
+
+		ports := rule.Ports
+		if rule.Proto == "icmp" && len(ports) == 0 {
+			ports = []string{"0"}
+		}
+		if len(ports) == 0 {
+			return nil, fmt.Errorf("categories[%d]: ports cannot be empty", i)
+		}
+
+		for _, value := range ports {
+			from, to, err := services.ParsePorts(value)
+			if err != nil {
+				return nil, fmt.Errorf("categories[%d]: %w", i, err)
+			}
+			rules = append(rules, services.Rule{Proto: rule.Proto, From: from, To: to, Category: rule.Category})
+		}
+	}
+	return rules, nil
+}
+
+// GetSource возвращает источник по имени
+func (c *Config) GetSource(name string) (SourceConfig, bool) {
+	for _, src := range c.Sources {
+		if src.Name == name {
+			return src, true
+		}
+	}
+	return SourceConfig{}, false
+}
+
 func (c *Config) GetFriendlyName(mac string) string {
 	// Normalize MAC address to lowercase for case-insensitive lookup
 	normalizedMAC := strings.ToLower(mac)
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestGetFriendlyName_CaseInsensitive(t *testing.T) {
@@ -164,3 +165,310 @@ func TestConfig_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestWatcher_Reload(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	write := func(content string) {
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+	}
+
+	write("data_dir: ./data\nserver_port: 8080\n")
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	watcher := NewWatcher(configPath, cfg, time.Second)
+
+	var changes int
+	watcher.OnChange(func(old, new *Config) {
+		changes++
+		if old != cfg {
+			t.Error("OnChange received unexpected previous config")
+		}
+	})
+
+	// Невалидный конфиг не должен заменить текущий
+	write("data_dir: ./data\nserver_port: 0\n")
+	if _, err := watcher.Reload(); err == nil {
+		t.Error("Expected error for invalid config")
+	}
+	if watcher.Current() != cfg || changes != 0 {
+		t.Error("Invalid config must not replace current config")
+	}
+
+	write("data_dir: ./other\nserver_port: 9090\n")
+	newCfg, err := watcher.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if changes != 1 || watcher.Current() != newCfg {
+		t.Error("Valid config was not applied")
+	}
+	if newCfg.ServerPort != 9090 || filepath.Base(newCfg.DataDir) != "other" {
+		t.Errorf("Unexpected reloaded config: %+v", newCfg)
+	}
+
+	// Удалённый файл не должен пересоздаваться конфигом по умолчанию
+	os.Remove(configPath)
+	if _, err := watcher.Reload(); err == nil {
+		t.Error("Expected error for missing config")
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Error("Reload must not create default config")
+	}
+}
+
+func TestLoad_LiveDefaults(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `data_dir: ./data
+server_port: 8080
+live:
+  enabled: true
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Live.Socket != defaultLiveSocket {
+		t.Errorf("Expected default socket, got %q", cfg.Live.Socket)
+	}
+	if cfg.Live.Interval != defaultLiveInterval {
+		t.Errorf("Expected default interval, got %s", cfg.Live.Interval)
+	}
+}
+
+func TestLoad_Sources(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		expectErr    bool
+		wantSources  []string
+		wantWarnings int
+	}{
+		{
+			name:        "data_dir becomes default source",
+			content:     "data_dir: ./data\nserver_port: 8080\n",
+			wantSources: []string{DefaultSourceName},
+		},
+		{
+			name: "multiple sources",
+			content: `server_port: 8080
+sources:
+  - name: main
+    data_dir: ./main
+  - name: ap
+    data_dir: ./ap
+`,
+			wantSources: []string{"main", "ap"},
+		},
+		{
+			name: "sources take precedence over data_dir",
+			content: `server_port: 8080
+data_dir: ./data
+sources:
+  - name: main
+    data_dir: ./main
+`,
+			wantSources:  []string{"main"},
+			wantWarnings: 1,
+		},
+		{
+			name: "duplicate source names",
+			content: `server_port: 8080
+sources:
+  - name: main
+    data_dir: ./main
+  - name: main
+    data_dir: ./ap
+`,
+			expectErr: true,
+		},
+		{
+			name: "source without data_dir",
+			content: `server_port: 8080
+sources:
+  - name: main
+`,
+			expectErr: true,
+		},
+		{
+			name: "http source",
+			content: `server_port: 8080
+sources:
+  - name: router
+    type: http
+    url: http://192.168.1.1:8080/api/raw
+    data_dir: ./mirror
+`,
+			wantSources: []string{"router"},
+		},
+		{
+			name: "http source without url",
+			content: `server_port: 8080
+sources:
+  - name: router
+    type: http
+    data_dir: ./mirror
+`,
+			expectErr: true,
+		},
+		{
+			name: "http source with too short interval",
+			content: `server_port: 8080
+sources:
+  - name: router
+    type: http
+    url: http://192.168.1.1:8080/api/raw
+    data_dir: ./mirror
+    interval: 1s
+`,
+			expectErr: true,
+		},
+		{
+			name: "unknown source type",
+			content: `server_port: 8080
+sources:
+  - name: router
+    type: ftp
+    data_dir: ./mirror
+`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			cfg, err := Load(configPath)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to load config: %v", err)
+			}
+
+			if len(cfg.Sources) != len(tt.wantSources) {
+				t.Fatalf("Expected %d sources, got %d", len(tt.wantSources), len(cfg.Sources))
+			}
+			for i, name := range tt.wantSources {
+				if cfg.Sources[i].Name != name || !filepath.IsAbs(cfg.Sources[i].DataDir) {
+					t.Errorf("Unexpected source %d: %+v", i, cfg.Sources[i])
+				}
+			}
+			if cfg.DataDir != cfg.Sources[0].DataDir {
+				t.Error("data_dir must point to the first source")
+			}
+			if len(cfg.Warnings) != tt.wantWarnings {
+				t.Errorf("Expected %d warnings, got %v", tt.wantWarnings, cfg.Warnings)
+			}
+		})
+	}
+}
+
+func TestLoad_Categories(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `data_dir: ./data
+server_port: 8080
+categories:
+  - category: Gaming
+    ports: [27015-27030, 3074]
+  - category: vpn
+    proto: UDP
+    ports: ["40000"]
+  - category: system
+    proto: icmp
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	rules, err := cfg.CategoryRules()
+	if err != nil || len(rules) != 4 {
+		t.Fatalf("Expected 4 rules, got %+v, %v", rules, err)
+	}
+	if rules[0].Category != "gaming" || rules[0].Proto != "any" || rules[0].From != 27015 || rules[0].To != 27030 {
+		t.Errorf("Unexpected range rule: %+v", rules[0])
+	}
+	if rules[1].From != 3074 || rules[2].Proto != "udp" || rules[3].Proto != "icmp" || rules[3].To != 0 {
+		t.Errorf("Unexpected rules: %+v", rules[1:])
+	}
+
+	for _, invalid := range []string{
+		"categories:\n  - category: web\n",
+		"categories:\n  - ports: [80]\n",
+		"categories:\n  - category: web\n    proto: sctp\n    ports: [80]\n",
+		"categories:\n  - category: web\n    ports: [80-70]\n",
+	} {
+		if err := os.WriteFile(configPath, []byte("data_dir: ./data\nserver_port: 8080\n"+invalid), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		if _, err := Load(configPath); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestLoad_Segments(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `data_dir: ./data
+server_port: 8080
+segments:
+  - name: " guest "
+    cidrs: [192.168.2.0/24, "fd00:2::/64"]
+  - name: iot
+    macs: ["AA-BB-CC-DD-EE-FF"]
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Segments) != 2 || cfg.Segments[0].Name != "guest" || cfg.Segments[1].MACs[0] != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("Unexpected segments: %+v", cfg.Segments)
+	}
+
+	for _, invalid := range []string{
+		"segments:\n  - cidrs: [10.0.0.0/8]\n",
+		"segments:\n  - name: guest\n",
+		"segments:\n  - name: guest\n    cidrs: [192.168.2.0]\n",
+		"segments:\n  - name: iot\n    macs: [nope]\n",
+		"segments:\n  - name: other\n    cidrs: [10.0.0.0/8]\n",
+		"segments:\n  - name: a\n    cidrs: [10.0.0.0/8]\n  - name: a\n    cidrs: [10.1.0.0/16]\n",
+		"segments:\n  - name: a\n    macs: ['aa:bb:cc:dd:ee:ff']\n  - name: b\n    macs: ['AA:BB:CC:DD:EE:FF']\n",
+		"segments:\n  - name: a\n    macs: ['aa:bb:cc:dd:ee:ff']\n  - name: b\n    macs: ['aa-bb-cc-dd-ee-ff']\n",
+	} {
+		if err := os.WriteFile(configPath, []byte("data_dir: ./data\nserver_port: 8080\n"+invalid), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		if _, err := Load(configPath); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
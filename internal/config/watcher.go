@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Watcher следит за config.yaml и перечитывает его при изменении
+type Watcher struct {
+	path     string
+	interval time.Duration
+
+	reloadMu sync.Mutex // сериализует перезагрузки и вызовы onChange
+	mu       sync.Mutex
+	current  *Config
+	modTime  time.Time
+	size     int64
+	onChange func(old, new *Config)
+}
+
+// NewWatcher создаёт наблюдателя для уже загруженного конфига
+func NewWatcher(path string, current *Config, interval time.Duration) *Watcher {
+	w := &Watcher{
+		path:     path,
+		interval: interval,
+		current:  current,
+	}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime = info.ModTime()
+		w.size = info.Size()
+	}
+	return w
+}
+
+// OnChange задаёт обработчик, который вызывается после успешной перезагрузки
+func (w *Watcher) OnChange(fn func(old, new *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = fn
+}
+
+// Current возвращает последний успешно загруженный конфиг
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload перечитывает и валидирует конфиг.
+// При ошибке текущий конфиг остаётся без изменений.
+func (w *Watcher) Reload() (*Config, error) {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	// Load создаёт конфиг по умолчанию, если файла нет - при перезагрузке
+	// это нежелательно, поэтому проверяем наличие файла заранее
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat config: %w", err)
+	}
+
+	w.mu.Lock()
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+	w.mu.Unlock()
+
+	cfg, err := Load(w.path)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	old := w.current
+	w.current = cfg
+	onChange := w.onChange
+	w.mu.Unlock()
+
+	if onChange != nil {
+		onChange(old, cfg)
+	}
+
+	return cfg, nil
+}
+
+// Run периодически проверяет mtime/размер файла и перезагружает конфиг.
+// Блокируется до закрытия stop.
+func (w *Watcher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+			fmt.Printf("Config file changed: %s\n", w.path)
+			if _, err := w.Reload(); err != nil {
+				fmt.Printf("Config reload failed, keeping previous config: %v\n", err)
+			}
+		}
+	}
+}
+
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
@@ -2,14 +2,16 @@ package converter
 
 import (
 	"compress/gzip"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+
+	"nlbw-ui/internal/services"
 )
 
 const (
@@ -50,11 +52,55 @@ type Record struct {
 	InBytes  uint64
 }
 
+// TruncatedError - база прочитана не полностью: в заголовке Expected записей,
+// а прочитать удалось только Read (файл обрезан или ещё пишется nlbwmon).
+// ConvertFile и ConvertReader вместе с этой ошибкой возвращают прочитанные записи.
+type TruncatedError struct {
+	Expected uint32
+	Read     uint32
+	Err      error
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("truncated database: read %d of %d records: %v", e.Read, e.Expected, e.Err)
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return e.Err
+}
+
 type TrafficData struct {
 	Columns []string        `json:"columns"`
 	Data    [][]interface{} `json:"data"`
 }
 
+// Extensions - поддерживаемые форматы файлов в порядке проверки.
+// .db.gz и .db - бинарный формат nlbwmon, .json и .csv - экспорт `nlbw -c`.
+var Extensions = []string{".db.gz", ".db", ".json", ".csv"}
+
+// TrimExtension отрезает от имени файла поддерживаемое расширение.
+// Второе значение false, если формат не поддерживается.
+func TrimExtension(filename string) (string, bool) {
+	for _, ext := range Extensions {
+		if strings.HasSuffix(filename, ext) {
+			return strings.TrimSuffix(filename, ext), true
+		}
+	}
+	return filename, false
+}
+
+// DateFromFilename извлекает дату YYYY-MM-DD из имени файла (YYYYMMDD.db.gz, YYYYMMDD.json, ...).
+// Имя без даты возвращается без расширения.
+func DateFromFilename(filename string) string {
+	dateStr, _ := TrimExtension(filepath.Base(filename))
+
+	if len(dateStr) == 8 {
+		// YYYYMMDD -> YYYY-MM-DD
+		return dateStr[0:4] + "-" + dateStr[4:6] + "-" + dateStr[6:8]
+	}
+	return dateStr
+}
+
 type Converter struct{}
 
 func New() *Converter {
@@ -62,15 +108,32 @@ func New() *Converter {
 }
 
 func (c *Converter) ConvertFile(filename string) (*TrafficData, error) {
+	switch {
+	case strings.HasSuffix(filename, ".json"):
+		return c.convertExport(filename, ParseJSON)
+	case strings.HasSuffix(filename, ".csv"):
+		return c.convertExport(filename, ParseCSV)
+	}
+
 	db, records, err := c.readDatabase(filename)
-	if err != nil {
+	if db == nil {
 		return nil, err
 	}
 
-	_ = db
-
+	// При TruncatedError отдаём то, что удалось прочитать, вместе с ошибкой
 	c.sortRecords(records)
-	return c.recordsToJSON(records), nil
+	return c.recordsToJSON(records), err
+}
+
+// convertExport читает текстовый экспорт nlbw (json/csv)
+func (c *Converter) convertExport(filename string, parse func(io.Reader) (*TrafficData, error)) (*TrafficData, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return parse(file)
 }
 
 func (c *Converter) readDatabase(filename string) (*Database, []Record, error) {
@@ -91,23 +154,32 @@ func (c *Converter) readDatabase(filename string) (*Database, []Record, error) {
 		reader = gzReader
 	}
 
-	db := &Database{}
-	if err := binary.Read(reader, binary.BigEndian, db); err != nil {
-		return nil, nil, fmt.Errorf("failed to read database header: %w", err)
-	}
+	return c.decodeDatabase(reader)
+}
 
-	if db.Magic != Magic {
-		return nil, nil, fmt.Errorf("invalid magic number: 0x%x (expected 0x%x)", db.Magic, Magic)
+// ConvertReader читает несжатую базу nlbwmon (заголовок + записи) из потока.
+// Такой же формат nlbwmon отдаёт через управляющий сокет на команду dump.
+func (c *Converter) ConvertReader(reader io.Reader) (*TrafficData, error) {
+	db, records, err := c.decodeDatabase(reader)
+	if db == nil {
+		return nil, err
 	}
 
-	records := make([]Record, db.Entries)
-	for i := uint32(0); i < db.Entries; i++ {
-		if err := binary.Read(reader, binary.BigEndian, &records[i]); err != nil {
-			return nil, nil, fmt.Errorf("failed to read record %d: %w", i, err)
-		}
+	c.sortRecords(records)
+	return c.recordsToJSON(records), err
+}
+
+// ValidateGzip проверяет, что поток - сжатая gzip база nlbwmon:
+// корректный заголовок с magic и все заявленные в нём записи
+func (c *Converter) ValidateGzip(reader io.Reader) (*Database, error) {
+	gzReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
 	}
+	defer gzReader.Close()
 
-	return db, records, nil
+	db, _, err := c.decodeDatabase(gzReader)
+	return db, err
 }
 
 func (c *Converter) sortRecords(records []Record) {
@@ -172,7 +244,7 @@ func (c *Converter) recordsToJSON(records []Record) *TrafficData {
 		row[7] = rec.InPkts
 		row[8] = rec.OutBytes
 		row[9] = rec.OutPkts
-		row[10] = nil
+		row[10] = layer7(row[1].(string), rec.DstPort)
 
 		output.Data = append(output.Data, row)
 	}
@@ -180,23 +252,36 @@ func (c *Converter) recordsToJSON(records []Record) *TrafficData {
 	return output
 }
 
-func formatProto(proto uint8) string {
-	protoNames := map[uint8]string{
-		0:   "HOPOPT",
-		1:   "ICMP",
-		2:   "IGMP",
-		4:   "IP-IN-IP",
-		6:   "TCP",
-		17:  "UDP",
-		41:  "IPV6-IN-IP",
-		47:  "GRE",
-		50:  "ESP",
-		51:  "AH",
-		58:  "IPV6-ICMP",
-		94:  "IPIP",
-		115: "L2TPV3",
+// layer7 возвращает название известного сервиса по протоколу и порту.
+// В бинарной базе nlbwmon layer7 нет, поэтому колонка заполняется по встроенной
+// таблице services; для неизвестных портов остаётся nil, как раньше.
+// Это только название сервиса: правила categories из конфига его не меняют,
+// категорию определяет services.Classifier, где эти правила проверяются первыми.
+func layer7(proto string, port uint16) interface{} {
+	if name := services.Name(proto, port); name != "" {
+		return name
 	}
+	return nil
+}
 
+// protoNames - имена протоколов в выводе, как у nlbw
+var protoNames = map[uint8]string{
+	0:   "HOPOPT",
+	1:   "ICMP",
+	2:   "IGMP",
+	4:   "IP-IN-IP",
+	6:   "TCP",
+	17:  "UDP",
+	41:  "IPV6-IN-IP",
+	47:  "GRE",
+	50:  "ESP",
+	51:  "AH",
+	58:  "IPV6-ICMP",
+	94:  "IPIP",
+	115: "L2TPV3",
+}
+
+func formatProto(proto uint8) string {
 	if name, ok := protoNames[proto]; ok {
 		return name
 	}
@@ -0,0 +1,293 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testRecords генерирует n случайных записей
+func testRecords(n int, seed int64) []Record {
+	rng := rand.New(rand.NewSource(seed))
+	records := make([]Record, n)
+	for i := range records {
+		rec := &records[i]
+		rec.Family = AF_INET
+		if rng.Intn(4) == 0 {
+			rec.Family = AF_INET6
+		}
+		rec.Proto = []uint8{1, 6, 17}[rng.Intn(3)]
+		rec.DstPort = uint16(rng.Intn(65536))
+		rng.Read(rec.SrcMAC[:6])
+		rng.Read(rec.SrcAddr[:])
+		rec.Count = rng.Uint64() >> 40
+		rec.OutPkts = rng.Uint64() >> 30
+		rec.OutBytes = rng.Uint64() >> 20
+		rec.InPkts = rng.Uint64() >> 30
+		rec.InBytes = rng.Uint64() >> 20
+	}
+	return records
+}
+
+// encodeTestDatabase собирает несжатую базу через binary.Write -
+// эталонную реализацию формата, с которой сверяется Decoder
+func encodeTestDatabase(entries uint32, records []Record) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, &Database{
+		Magic:     Magic,
+		Entries:   entries,
+		Timestamp: 1700000000,
+		Interval:  Interval{Type: 1, Base: 1700000000, Value: 1},
+	})
+	for i := range records {
+		binary.Write(&buf, binary.BigEndian, &records[i])
+	}
+	return buf.Bytes()
+}
+
+func TestDecoder_MatchesBinaryRead(t *testing.T) {
+	records := testRecords(100, 1)
+	data := encodeTestDatabase(uint32(len(records)), records)
+
+	db, decoded, err := New().decodeDatabase(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decodeDatabase failed: %v", err)
+	}
+	if db.Entries != 100 || db.Timestamp != 1700000000 || db.Interval.Base != 1700000000 || db.Interval.Value != 1 {
+		t.Errorf("Unexpected header: %+v", db)
+	}
+	if len(decoded) != len(records) {
+		t.Fatalf("Expected %d records, got %d", len(records), len(decoded))
+	}
+	for i := range records {
+		if decoded[i] != records[i] {
+			t.Fatalf("Record %d differs:\n got  %+v\n want %+v", i, decoded[i], records[i])
+		}
+	}
+}
+
+func TestDecoder_Truncated(t *testing.T) {
+	records := testRecords(10, 2)
+	data := encodeTestDatabase(10, records)
+
+	// Обрыв посреди 8-й записи
+	cut := HeaderSize + 7*RecordDiskSize + 30
+	_, decoded, err := New().decodeDatabase(bytes.NewReader(data[:cut]))
+
+	var truncated *TruncatedError
+	if !errors.As(err, &truncated) {
+		t.Fatalf("Expected TruncatedError, got %v", err)
+	}
+	if truncated.Read != 7 || truncated.Expected != 10 || len(decoded) != 7 {
+		t.Errorf("Unexpected truncation: %+v, %d records", truncated, len(decoded))
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestDecoder_HugeEntriesDoNotAllocate(t *testing.T) {
+	// Заголовок обещает ~4 млрд записей, на деле их 2
+	data := encodeTestDatabase(0xffffffff, testRecords(2, 3))
+
+	allocs := testing.AllocsPerRun(10, func() {
+		_, decoded, err := New().decodeDatabase(bytes.NewReader(data))
+		if len(decoded) != 2 || err == nil {
+			t.Fatalf("Expected 2 records and an error, got %d, %v", len(decoded), err)
+		}
+	})
+
+	// Без ограничения здесь была бы попытка выделить ~300 ГБ
+	if allocs > 20 {
+		t.Errorf("Too many allocations: %v", allocs)
+	}
+}
+
+func TestDecoder_InvalidMagic(t *testing.T) {
+	data := encodeTestDatabase(1, testRecords(1, 4))
+	data[0] = 0
+
+	if _, _, err := New().decodeDatabase(bytes.NewReader(data)); err == nil {
+		t.Error("Expected error for invalid magic")
+	}
+}
+
+func FuzzDecodeDatabase(f *testing.F) {
+	valid := encodeTestDatabase(3, testRecords(3, 5))
+	f.Add(valid)
+	f.Add(valid[:HeaderSize])
+	f.Add(valid[:HeaderSize+RecordDiskSize+1])
+	f.Add(encodeTestDatabase(0xffffffff, nil))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		db, records, err := New().decodeDatabase(bytes.NewReader(data))
+		if db == nil {
+			if records != nil || err == nil {
+				t.Fatalf("No header but records=%d err=%v", len(records), err)
+			}
+			return
+		}
+
+		maxRecords := (len(data) - HeaderSize) / RecordDiskSize
+		if len(records) > maxRecords {
+			t.Fatalf("Decoded %d records from %d bytes", len(records), len(data))
+		}
+
+		if err == nil && uint32(len(records)) != db.Entries {
+			t.Fatalf("Header says %d records, decoded %d without error", db.Entries, len(records))
+		}
+
+		var truncated *TruncatedError
+		if err != nil && (!errors.As(err, &truncated) || truncated.Read != uint32(len(records))) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func benchmarkDatabase(b *testing.B, n int) []byte {
+	b.Helper()
+	return encodeTestDatabase(uint32(n), testRecords(n, 6))
+}
+
+func BenchmarkDecodeDatabase(b *testing.B) {
+	data := benchmarkDatabase(b, 10000)
+	c := New()
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := c.decodeDatabase(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBinaryRead - прежний способ чтения через binary.Read, для сравнения
+func BenchmarkBinaryRead(b *testing.B) {
+	data := benchmarkDatabase(b, 10000)
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		reader := bytes.NewReader(data)
+		var db Database
+		binary.Read(reader, binary.BigEndian, &db)
+		records := make([]Record, db.Entries)
+		for j := range records {
+			if err := binary.Read(reader, binary.BigEndian, &records[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkConvertReader(b *testing.B) {
+	data := benchmarkDatabase(b, 10000)
+	c := New()
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := c.ConvertReader(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncode_ByteExact(t *testing.T) {
+	records := testRecords(50, 7)
+	want := encodeTestDatabase(uint32(len(records)), records)
+
+	var buf bytes.Buffer
+	db := Database{Timestamp: 1700000000, Interval: Interval{Type: 1, Base: 1700000000, Value: 1}}
+	if err := New().Encode(&buf, db, records); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatal("Encoded database differs from the reference layout")
+	}
+}
+
+func TestWriteFile_RoundTrip(t *testing.T) {
+	records := testRecords(200, 8)
+	db := Database{Timestamp: 1700000000, Interval: Interval{Type: 1, Base: 1699990000, Value: -1}}
+
+	for _, name := range []string{"20240101.db.gz", "20240101.db"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			c := New()
+			if err := c.WriteFile(path, db, records); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+
+			gotDB, got, err := c.readDatabase(path)
+			if err != nil {
+				t.Fatalf("readDatabase failed: %v", err)
+			}
+			if gotDB.Magic != Magic || gotDB.Entries != uint32(len(records)) ||
+				gotDB.Timestamp != db.Timestamp || gotDB.Interval != db.Interval {
+				t.Errorf("Header mismatch: %+v", gotDB)
+			}
+			if len(got) != len(records) {
+				t.Fatalf("Expected %d records, got %d", len(records), len(got))
+			}
+			for i := range records {
+				if got[i] != records[i] {
+					t.Fatalf("Record %d differs after round trip", i)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordsFromTrafficData_RoundTrip(t *testing.T) {
+	records := testRecords(100, 9)
+	for i := range records {
+		// Для IPv4 в выводе участвуют только первые 4 байта адреса
+		if records[i].Family == AF_INET {
+			for j := 4; j < 16; j++ {
+				records[i].SrcAddr[j] = 0
+			}
+		}
+	}
+
+	c := New()
+	c.sortRecords(records)
+	data := c.recordsToJSON(records)
+
+	got, err := RecordsFromTrafficData(data)
+	if err != nil {
+		t.Fatalf("RecordsFromTrafficData failed: %v", err)
+	}
+	for i := range records {
+		if got[i] != records[i] {
+			t.Fatalf("Record %d differs:\n got  %+v\n want %+v", i, got[i], records[i])
+		}
+	}
+
+	// И обратно через файл: TrafficData -> .db.gz -> TrafficData
+	path := filepath.Join(t.TempDir(), "20240101.db.gz")
+	if err := c.WriteFile(path, Database{}, got); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	converted, err := c.ConvertFile(path)
+	if err != nil {
+		t.Fatalf("ConvertFile failed: %v", err)
+	}
+	if !reflect.DeepEqual(converted.Data, data.Data) {
+		t.Error("TrafficData differs after writing and reading back")
+	}
+}
@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseCSV разбирает вывод `nlbw -c csv`.
+// По умолчанию nlbw разделяет поля табуляцией, но разделитель можно
+// сменить ключом -s, поэтому он определяется по строке заголовка.
+func ParseCSV(reader io.Reader) (*TrafficData, error) {
+	buffered := bufio.NewReader(reader)
+
+	header, err := buffered.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	csvReader := csv.NewReader(buffered)
+	csvReader.Comma = detectDelimiter(string(header))
+	csvReader.LazyQuotes = true
+	csvReader.FieldsPerRecord = -1
+
+	columns, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	index, err := columnIndex(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &TrafficData{
+		Columns: Columns,
+		Data:    make([][]interface{}, 0),
+	}
+
+	for line := 2; ; line++ {
+		fields, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		row, err := buildRow(fields, index)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		output.Data = append(output.Data, row)
+	}
+
+	return output, nil
+}
+
+// detectDelimiter выбирает самый частый из допустимых разделителей в первой строке
+func detectDelimiter(sample string) rune {
+	if i := strings.IndexByte(sample, '\n'); i >= 0 {
+		sample = sample[:i]
+	}
+
+	best, bestCount := '\t', 0
+	for _, candidate := range []rune{'\t', ',', ';'} {
+		if count := strings.Count(sample, string(candidate)); count > bestCount {
+			best, bestCount = candidate, count
+		}
+	}
+	return best
+}
@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// csvExport - выгрузка `nlbw -c csv` с разделителем sep
+func csvExport(sep string) string {
+	lines := [][]string{
+		{"family", "proto", "port", "mac", "ip", "conns", "rx_bytes", "rx_pkts", "tx_bytes", "tx_pkts", "layer7"},
+		{"ipv4", "TCP", "443", "AA:BB:CC:DD:EE:01", "192.168.1.10", "150", "3000000", "2400", "180000", "1000", ""},
+		{"4", "17", "53", "aa:bb:cc:dd:ee:01", "192.168.1.10", "40", "6000", "40", "3000", "40", "DNS"},
+		{"ipv6", "tcp", "8443", "aa:bb:cc:dd:ee:02", "2001:db8::2", "10", "400000", "300", "20000", "100", ""},
+	}
+
+	var b strings.Builder
+	for _, fields := range lines {
+		b.WriteString(strings.Join(fields, sep))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestParseCSV_Delimiters(t *testing.T) {
+	expected := [][]interface{}{
+		{4, "TCP", uint16(443), "aa:bb:cc:dd:ee:01", "192.168.1.10", uint64(150), uint64(3000000), uint64(2400), uint64(180000), uint64(1000), "HTTPS"},
+		{4, "UDP", uint16(53), "aa:bb:cc:dd:ee:01", "192.168.1.10", uint64(40), uint64(6000), uint64(40), uint64(3000), uint64(40), "DNS"},
+		{6, "TCP", uint16(8443), "aa:bb:cc:dd:ee:02", "2001:db8::2", uint64(10), uint64(400000), uint64(300), uint64(20000), uint64(100), layer7("TCP", 8443)},
+	}
+
+	for name, sep := range map[string]string{"tab": "\t", "comma": ",", "semicolon": ";"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := ParseCSV(strings.NewReader(csvExport(sep)))
+			if err != nil {
+				t.Fatalf("ParseCSV failed: %v", err)
+			}
+			if !reflect.DeepEqual(data.Data, expected) {
+				t.Errorf("Unexpected rows:\n%v\nexpected:\n%v", data.Data, expected)
+			}
+		})
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	cases := map[string]rune{
+		"family\tproto\tport\n1,2,3,4,5,6\n": '\t', // считается только первая строка
+		"family,proto,port":                  ',',
+		"family;proto;port;mac":              ';',
+		"family;proto,port;mac":              ';',
+		"family":                             '\t', // по умолчанию - как у nlbw
+		"":                                   '\t',
+	}
+	for sample, expected := range cases {
+		if got := detectDelimiter(sample); got != expected {
+			t.Errorf("detectDelimiter(%q) = %q, expected %q", sample, got, expected)
+		}
+	}
+}
+
+func TestParseCSV_Invalid(t *testing.T) {
+	header := "family,proto,port,mac,ip,conns,rx_bytes,rx_pkts,tx_bytes,tx_pkts\n"
+	cases := map[string]string{
+		"missing column": "family,proto,port,mac,ip,conns,rx_bytes,rx_pkts,tx_bytes\n4,TCP,443,aa,1.1.1.1,1,1,1,1\n",
+		"bad family":     header + "5,TCP,443,aa,1.1.1.1,1,1,1,1,1\n",
+		"bad port":       header + "4,TCP,70000,aa,1.1.1.1,1,1,1,1,1\n",
+		"bad counter":    header + "4,TCP,443,aa,1.1.1.1,1,-1,1,1,1\n",
+		"empty":          "",
+	}
+	for name, content := range cases {
+		if _, err := ParseCSV(strings.NewReader(content)); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+// CSV -> база nlbwmon -> ConvertFile даёт те же строки
+func TestParseCSV_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "20240101.csv")
+	if err := os.WriteFile(csvPath, []byte(csvExport("\t")), 0644); err != nil {
+		t.Fatalf("Failed to write csv: %v", err)
+	}
+
+	c := New()
+	fromCSV, err := c.ConvertFile(csvPath)
+	if err != nil {
+		t.Fatalf("ConvertFile(csv) failed: %v", err)
+	}
+
+	records, err := RecordsFromTrafficData(fromCSV)
+	if err != nil {
+		t.Fatalf("RecordsFromTrafficData failed: %v", err)
+	}
+	dbPath := filepath.Join(dir, "20240101.db.gz")
+	if err := c.WriteFile(dbPath, Database{}, records); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	fromDB, err := c.ConvertFile(dbPath)
+	if err != nil {
+		t.Fatalf("ConvertFile(db) failed: %v", err)
+	}
+
+	// layer7 в бинарном формате нет - он восстанавливается по таблице сервисов
+	byKey := func(data *TrafficData) map[string][]interface{} {
+		rows := make(map[string][]interface{})
+		for _, row := range data.Data {
+			rows[fmt.Sprintf("%v/%v/%v", row[1], row[2], row[4])] = row[:10]
+		}
+		return rows
+	}
+	if !reflect.DeepEqual(byKey(fromCSV), byKey(fromDB)) {
+		t.Errorf("Round trip changed rows:\n%v\n%v", fromCSV.Data, fromDB.Data)
+	}
+}
@@ -0,0 +1,116 @@
+package converter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxPreallocRecords - сколько записей резервируется заранее.
+// Значению Entries из заголовка не доверяем: у повреждённого файла там
+// может быть что угодно, поэтому дальше слайс растёт по мере чтения.
+const maxPreallocRecords = 16384
+
+// Decoder потоково читает базу nlbwmon: 40-байтный заголовок и
+// записи по 72 байта (big endian) без reflection и лишних аллокаций
+type Decoder struct {
+	reader    *bufio.Reader
+	buf       [RecordDiskSize]byte
+	db        *Database
+	remaining uint32
+}
+
+// NewDecoder создаёт декодер поверх reader (несжатый поток)
+func NewDecoder(reader io.Reader) *Decoder {
+	return &Decoder{reader: bufio.NewReaderSize(reader, 64*1024)}
+}
+
+// Header читает и проверяет заголовок. Вызывается первым и один раз.
+func (d *Decoder) Header() (*Database, error) {
+	header := d.buf[:HeaderSize]
+	if _, err := io.ReadFull(d.reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read database header: %w", err)
+	}
+
+	db := &Database{
+		Magic:     binary.BigEndian.Uint32(header[0:4]),
+		Entries:   binary.BigEndian.Uint32(header[4:8]),
+		Timestamp: binary.BigEndian.Uint32(header[8:12]),
+		Interval: Interval{
+			Type:  header[16],
+			Base:  binary.BigEndian.Uint64(header[24:32]),
+			Value: int32(binary.BigEndian.Uint32(header[32:36])),
+		},
+	}
+
+	if db.Magic != Magic {
+		return nil, fmt.Errorf("invalid magic number: 0x%x (expected 0x%x)", db.Magic, Magic)
+	}
+
+	d.db = db
+	d.remaining = db.Entries
+	return db, nil
+}
+
+// Next читает следующую запись в rec.
+// Возвращает io.EOF после последней заявленной в заголовке записи и
+// io.ErrUnexpectedEOF, если поток закончился раньше.
+func (d *Decoder) Next(rec *Record) error {
+	if d.db == nil {
+		return errors.New("header must be read before records")
+	}
+	if d.remaining == 0 {
+		return io.EOF
+	}
+
+	if _, err := io.ReadFull(d.reader, d.buf[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	d.remaining--
+
+	b := d.buf[:]
+	rec.Family = b[0]
+	rec.Proto = b[1]
+	rec.DstPort = binary.BigEndian.Uint16(b[2:4])
+	copy(rec.SrcMAC[:], b[8:16])
+	copy(rec.SrcAddr[:], b[16:32])
+	rec.Count = binary.BigEndian.Uint64(b[32:40])
+	rec.OutPkts = binary.BigEndian.Uint64(b[40:48])
+	rec.OutBytes = binary.BigEndian.Uint64(b[48:56])
+	rec.InPkts = binary.BigEndian.Uint64(b[56:64])
+	rec.InBytes = binary.BigEndian.Uint64(b[64:72])
+	return nil
+}
+
+// decodeDatabase читает заголовок и все записи.
+// Если поток короче, чем обещает заголовок, возвращает прочитанные
+// записи вместе с *TruncatedError.
+func (c *Converter) decodeDatabase(reader io.Reader) (*Database, []Record, error) {
+	dec := NewDecoder(reader)
+
+	db, err := dec.Header()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records := make([]Record, 0, min(db.Entries, maxPreallocRecords))
+	for {
+		var rec Record
+		err := dec.Next(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Записи до повреждённой остаются валидными
+			return db, records, &TruncatedError{Expected: db.Entries, Read: uint32(len(records)), Err: err}
+		}
+		records = append(records, rec)
+	}
+
+	return db, records, nil
+}
@@ -0,0 +1,228 @@
+package converter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Encoder пишет базу nlbwmon в том же формате, который читает Decoder:
+// 40-байтный заголовок и записи по 72 байта (big endian), паддинг нулями
+type Encoder struct {
+	writer *bufio.Writer
+	buf    [RecordDiskSize]byte
+}
+
+// NewEncoder создаёт энкодер поверх writer (несжатый поток)
+func NewEncoder(writer io.Writer) *Encoder {
+	return &Encoder{writer: bufio.NewWriterSize(writer, 64*1024)}
+}
+
+// WriteHeader пишет заголовок. Magic всегда записывается как Magic.
+func (e *Encoder) WriteHeader(db *Database) error {
+	header := e.buf[:HeaderSize]
+	for i := range header {
+		header[i] = 0
+	}
+
+	binary.BigEndian.PutUint32(header[0:4], Magic)
+	binary.BigEndian.PutUint32(header[4:8], db.Entries)
+	binary.BigEndian.PutUint32(header[8:12], db.Timestamp)
+	header[16] = db.Interval.Type
+	binary.BigEndian.PutUint64(header[24:32], db.Interval.Base)
+	binary.BigEndian.PutUint32(header[32:36], uint32(db.Interval.Value))
+
+	_, err := e.writer.Write(header)
+	return err
+}
+
+// Write пишет одну запись
+func (e *Encoder) Write(rec *Record) error {
+	b := e.buf[:]
+	for i := range b {
+		b[i] = 0
+	}
+
+	b[0] = rec.Family
+	b[1] = rec.Proto
+	binary.BigEndian.PutUint16(b[2:4], rec.DstPort)
+	copy(b[8:16], rec.SrcMAC[:])
+	copy(b[16:32], rec.SrcAddr[:])
+	binary.BigEndian.PutUint64(b[32:40], rec.Count)
+	binary.BigEndian.PutUint64(b[40:48], rec.OutPkts)
+	binary.BigEndian.PutUint64(b[48:56], rec.OutBytes)
+	binary.BigEndian.PutUint64(b[56:64], rec.InPkts)
+	binary.BigEndian.PutUint64(b[64:72], rec.InBytes)
+
+	_, err := e.writer.Write(b)
+	return err
+}
+
+// Flush сбрасывает буфер в нижележащий writer
+func (e *Encoder) Flush() error {
+	return e.writer.Flush()
+}
+
+// Encode пишет несжатую базу с records; Entries берётся из len(records)
+func (c *Converter) Encode(writer io.Writer, db Database, records []Record) error {
+	db.Entries = uint32(len(records))
+
+	enc := NewEncoder(writer)
+	if err := enc.WriteHeader(&db); err != nil {
+		return fmt.Errorf("failed to write database header: %w", err)
+	}
+	for i := range records {
+		if err := enc.Write(&records[i]); err != nil {
+			return fmt.Errorf("failed to write record %d: %w", i, err)
+		}
+	}
+	return enc.Flush()
+}
+
+// WriteFile атомарно записывает базу в filename (через временный файл и rename).
+// Для имён, оканчивающихся на .gz, база сжимается gzip, как это делает nlbwmon.
+func (c *Converter) WriteFile(filename string, db Database, records []Record) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	var writer io.Writer = tmp
+	var gzWriter *gzip.Writer
+	if strings.HasSuffix(filename, ".gz") {
+		gzWriter = gzip.NewWriter(tmp)
+		writer = gzWriter
+	}
+
+	if err := c.Encode(writer, db, records); err != nil {
+		tmp.Close()
+		return err
+	}
+	if gzWriter != nil {
+		if err := gzWriter.Close(); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compress database: %w", err)
+		}
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// RecordsFromTrafficData - обратное преобразование к recordsToJSON:
+// строки TrafficData превращаются в записи nlbwmon. Колонка layer7 не сохраняется,
+// в бинарном формате её нет.
+func RecordsFromTrafficData(data *TrafficData) ([]Record, error) {
+	records := make([]Record, 0, len(data.Data))
+
+	for i, row := range data.Data {
+		if len(row) < 10 {
+			return nil, fmt.Errorf("row %d: expected at least 10 columns, got %d", i, len(row))
+		}
+
+		var rec Record
+		switch toUint64(row[0]) {
+		case 4:
+			rec.Family = AF_INET
+		case 6:
+			rec.Family = AF_INET6
+		default:
+			return nil, fmt.Errorf("row %d: invalid family %v", i, row[0])
+		}
+
+		proto, err := parseProto(fmt.Sprint(row[1]))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		rec.Proto = proto
+		rec.DstPort = uint16(toUint64(row[2]))
+
+		mac, err := net.ParseMAC(fmt.Sprint(row[3]))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid mac %v", i, row[3])
+		}
+		copy(rec.SrcMAC[:], mac)
+
+		if err := parseIP(rec.Family, fmt.Sprint(row[4]), &rec.SrcAddr); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+
+		rec.Count = toUint64(row[5])
+		rec.InBytes = toUint64(row[6])
+		rec.InPkts = toUint64(row[7])
+		rec.OutBytes = toUint64(row[8])
+		rec.OutPkts = toUint64(row[9])
+
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+// parseProto - обратное к formatProto
+func parseProto(name string) (uint8, error) {
+	for num, protoName := range protoNames {
+		if protoName == strings.ToUpper(name) {
+			return num, nil
+		}
+	}
+	if num, err := strconv.ParseUint(name, 10, 8); err == nil {
+		return uint8(num), nil
+	}
+	return 0, fmt.Errorf("unknown protocol %q", name)
+}
+
+// parseIP - обратное к formatIP: IPv4 хранится в обратном порядке байт
+func parseIP(family uint8, value string, addr *[16]byte) error {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return fmt.Errorf("invalid ip %q", value)
+	}
+
+	if family == AF_INET {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return fmt.Errorf("invalid ipv4 address %q", value)
+		}
+		addr[0], addr[1], addr[2], addr[3] = ip4[3], ip4[2], ip4[1], ip4[0]
+		return nil
+	}
+
+	copy(addr[:], ip.To16())
+	return nil
+}
+
+// toUint64 приводит числовое значение строки TrafficData к uint64
+func toUint64(value interface{}) uint64 {
+	switch v := value.(type) {
+	case uint64:
+		return v
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case int:
+		return uint64(v)
+	case int64:
+		return uint64(v)
+	case float64:
+		return uint64(v)
+	}
+	return 0
+}
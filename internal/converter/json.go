@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Columns - порядок колонок в TrafficData (совпадает с выводом nlbw -c json)
+var Columns = []string{"family", "proto", "port", "mac", "ip", "conns", "rx_bytes", "rx_pkts", "tx_bytes", "tx_pkts", "layer7"}
+
+// ParseJSON разбирает вывод `nlbw -c json`.
+// Значения приводятся к тем же типам, что и у recordsToJSON,
+// колонки сопоставляются по имени, а не по позиции.
+func ParseJSON(reader io.Reader) (*TrafficData, error) {
+	var raw struct {
+		Columns []string            `json:"columns"`
+		Data    [][]json.RawMessage `json:"data"`
+	}
+
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode json: %w", err)
+	}
+
+	index, err := columnIndex(raw.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &TrafficData{
+		Columns: Columns,
+		Data:    make([][]interface{}, 0, len(raw.Data)),
+	}
+
+	for i, rawRow := range raw.Data {
+		fields := make([]string, len(rawRow))
+		for j, value := range rawRow {
+			fields[j] = unquoteJSON(value)
+		}
+
+		row, err := buildRow(fields, index)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		output.Data = append(output.Data, row)
+	}
+
+	return output, nil
+}
+
+// unquoteJSON возвращает строковое представление JSON значения
+func unquoteJSON(value json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str
+	}
+
+	text := strings.TrimSpace(string(value))
+	if text == "null" {
+		return ""
+	}
+	return text
+}
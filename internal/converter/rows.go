@@ -0,0 +1,81 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// requiredColumns - колонки, без которых строку невозможно привести к TrafficData
+var requiredColumns = []string{"family", "proto", "port", "mac", "ip", "conns", "rx_bytes", "rx_pkts", "tx_bytes", "tx_pkts"}
+
+// columnIndex сопоставляет имена колонок экспорта с их позициями
+func columnIndex(columns []string) (map[string]int, error) {
+	index := make(map[string]int, len(columns))
+	for i, name := range columns {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range requiredColumns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	return index, nil
+}
+
+// buildRow приводит текстовые поля экспорта к строке TrafficData
+func buildRow(fields []string, index map[string]int) ([]interface{}, error) {
+	field := func(name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	row := make([]interface{}, 11)
+
+	switch field("family") {
+	case "4", "ipv4":
+		row[0] = 4
+	case "6", "ipv6":
+		row[0] = 6
+	default:
+		return nil, fmt.Errorf("invalid family %q", field("family"))
+	}
+
+	proto := field("proto")
+	if num, err := strconv.ParseUint(proto, 10, 8); err == nil {
+		row[1] = formatProto(uint8(num))
+	} else {
+		row[1] = strings.ToUpper(proto)
+	}
+
+	port, err := strconv.ParseUint(field("port"), 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", field("port"))
+	}
+	row[2] = uint16(port)
+
+	row[3] = strings.ToLower(field("mac"))
+	row[4] = field("ip")
+
+	counters := []string{"conns", "rx_bytes", "rx_pkts", "tx_bytes", "tx_pkts"}
+	for i, name := range counters {
+		value, err := strconv.ParseUint(field(name), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", name, field(name))
+		}
+		row[5+i] = value
+	}
+
+	// Экспорт nlbw содержит layer7 от nlbwmon, иначе - по таблице сервисов
+	row[10] = layer7(row[1].(string), row[2].(uint16))
+	if name := field("layer7"); name != "" {
+		row[10] = name
+	}
+
+	return row, nil
+}
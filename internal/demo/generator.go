@@ -2,6 +2,7 @@ package demo
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"strings"
@@ -40,45 +41,58 @@ func ParseDateRange(dateStr string) (*DateRange, error) {
 	return &DateRange{From: from, To: to}, nil
 }
 
-// Device представляет виртуальное устройство
-type Device struct {
-	MAC    string
-	IP     string
-	Name   string
-	IsIoT  bool // IoT устройство (умный дом) с малым трафиком
-}
-
-// Generator генерирует demo данные
+// Generator генерирует demo данные по сценарию.
+// Данные дня зависят только от seed и даты, поэтому при одном seed
+// любой день воспроизводится независимо от порядка генерации.
 type Generator struct {
-	devices []Device
-	rand    *rand.Rand
+	scenario *Scenario
+	seed     int64
 }
 
-// NewGenerator создает новый генератор с уникальным seed
+// NewGenerator создает генератор со сценарием по умолчанию и случайным seed
 func NewGenerator() *Generator {
-	// Используем текущее время с наносекундами для уникальности
-	seed := time.Now().UnixNano()
-
-	devices := []Device{
-		// 4 обычных устройства (до 15 ГБ/день)
-		{MAC: "00:11:22:33:44:55", IP: "192.168.1.10", Name: "Desktop PC", IsIoT: false},
-		{MAC: "aa:bb:cc:dd:ee:ff", IP: "192.168.1.20", Name: "Laptop", IsIoT: false},
-		{MAC: "12:34:56:78:9a:bc", IP: "192.168.1.30", Name: "iPhone", IsIoT: false},
-		{MAC: "fe:dc:ba:98:76:54", IP: "192.168.1.40", Name: "Smart TV", IsIoT: false},
-
-		// 6 IoT устройств (до 2 МБ/день)
-		{MAC: "11:22:33:44:55:66", IP: "192.168.1.50", Name: "Smart Thermostat", IsIoT: true},
-		{MAC: "aa:11:bb:22:cc:33", IP: "192.168.1.60", Name: "Smart Light", IsIoT: true},
-		{MAC: "ff:ee:dd:cc:bb:aa", IP: "192.168.1.70", Name: "Security Camera", IsIoT: true},
-		{MAC: "99:88:77:66:55:44", IP: "192.168.1.80", Name: "Smart Speaker", IsIoT: true},
-		{MAC: "bb:aa:99:88:77:66", IP: "192.168.1.90", Name: "Smart Lock", IsIoT: true},
-		{MAC: "cc:dd:ee:ff:00:11", IP: "192.168.1.100", Name: "Smart Plug", IsIoT: true},
+	return NewGeneratorWithSeed(time.Now().UnixNano(), DefaultScenario())
+}
+
+// NewGeneratorWithSeed создает детерминированный генератор.
+// scenario == nil - сценарий по умолчанию.
+func NewGeneratorWithSeed(seed int64, scenario *Scenario) *Generator {
+	if scenario == nil {
+		scenario = DefaultScenario()
+	}
+	return &Generator{scenario: scenario, seed: seed}
+}
+
+// Seed возвращает seed генератора, чтобы данные можно было воспроизвести
+func (g *Generator) Seed() int64 {
+	return g.seed
+}
+
+// Names возвращает имена устройств сценария по MAC
+func (g *Generator) Names() map[string]string {
+	names := make(map[string]string, len(g.scenario.Devices))
+	for _, device := range g.scenario.Devices {
+		names[device.MAC] = device.Name
 	}
+	return names
+}
 
-	return &Generator{
-		devices: devices,
-		rand:    rand.New(rand.NewSource(seed)),
+// dayProgress возвращает для каждого устройства долю его часов
+// активности, прошедшую к моменту now
+func (g *Generator) dayProgress(now time.Time) map[string]float64 {
+	progress := make(map[string]float64, len(g.scenario.Devices))
+	for i := range g.scenario.Devices {
+		device := &g.scenario.Devices[i]
+		progress[device.MAC] = device.activeFraction(now)
 	}
+	return progress
+}
+
+// randFor возвращает источник случайных чисел для даты
+func (g *Generator) randFor(date time.Time) *rand.Rand {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d/%s", g.seed, FormatDateForFilename(date))
+	return rand.New(rand.NewSource(int64(hash.Sum64())))
 }
 
 // GenerateForDate генерирует данные для конкретной даты
@@ -88,47 +102,39 @@ func (g *Generator) GenerateForDate(date time.Time) *converter.TrafficData {
 		Data:    make([][]interface{}, 0),
 	}
 
-	// 5% дней - пустые (нулевой трафик)
-	if g.rand.Float64() < 0.05 {
-		return data // возвращаем пустые данные
+	rng := g.randFor(date)
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Часть дней - пустые (нулевой трафик)
+	if rng.Float64() < g.scenario.EmptyDays {
+		return data
 	}
 
 	// Для каждого устройства генерируем записи
-	for _, device := range g.devices {
-		// Генерируем суммарный трафик устройства за день
-		var totalRx, totalTx uint64
-		var numRecords int
-
-		if device.IsIoT {
-			// IoT: 100 KB - 2 MB в день
-			totalRx = g.generateIoTTraffic()
-			totalTx = g.generateIoTTraffic()
-			numRecords = g.rand.Intn(3) + 1 // от 1 до 3 записей для IoT
-		} else {
-			// Обычные: с распределением по весам
-			totalRx = g.generateNormalDeviceTraffic()
-			// Upload обычно меньше download
-			uploadRatio := 0.3 + g.rand.Float64()*0.6
-			totalTx = g.generateNormalDeviceTrafficWithRatio(uploadRatio)
-			numRecords = g.rand.Intn(10) + 5 // от 5 до 15 записей для обычных
-		}
-
-		// Пропускаем устройства с нулевым трафиком
-		if totalRx == 0 && totalTx == 0 {
+	for i := range g.scenario.Devices {
+		device := &g.scenario.Devices[i]
+
+		// Случайные числа тратятся и на отсутствующие устройства, чтобы
+		// появление одного устройства не меняло трафик остальных
+		totalRx := generateVolume(rng, device.Volume)
+		uploadRatio := device.UploadRatio[0] + rng.Float64()*(device.UploadRatio[1]-device.UploadRatio[0])
+		totalTx := uint64(float64(generateVolume(rng, device.Volume)) * uploadRatio)
+		numRecords := device.Records[0] + rng.Intn(device.Records[1]-device.Records[0]+1)
+
+		// Пропускаем отсутствующие устройства и устройства с нулевым трафиком
+		if !device.activeOn(day) || (totalRx == 0 && totalTx == 0) {
 			continue
 		}
 
 		// Распределяем суммарный трафик между записями (протоколами/портами)
 		for i := 0; i < numRecords; i++ {
-			// Доля трафика для этой записи
-			portion := 1.0 / float64(numRecords)
-			// Добавляем случайную вариацию
-			portion = portion * (0.5 + g.rand.Float64())
+			// Доля трафика для этой записи со случайной вариацией
+			portion := 1.0 / float64(numRecords) * (0.5 + rng.Float64())
 
 			rxBytes := uint64(float64(totalRx) * portion)
 			txBytes := uint64(float64(totalTx) * portion)
 
-			row := g.generateRecordWithTraffic(device, rxBytes, txBytes)
+			row := generateRecordWithTraffic(rng, device, rxBytes, txBytes)
 			data.Data = append(data.Data, row)
 		}
 	}
@@ -137,27 +143,17 @@ func (g *Generator) GenerateForDate(date time.Time) *converter.TrafficData {
 }
 
 // generateRecordWithTraffic генерирует одну запись трафика с заданными объемами
-func (g *Generator) generateRecordWithTraffic(device Device, rxBytes, txBytes uint64) []interface{} {
-	protocols := []struct {
-		name  string
-		proto uint8
-		ports []uint16
-	}{
-		{"TCP", 6, []uint16{80, 443, 8080, 22, 3389, 5432, 3306}},
-		{"UDP", 17, []uint16{53, 123, 1194, 500, 4500}},
-		{"ICMP", 1, []uint16{0}},
+func generateRecordWithTraffic(rng *rand.Rand, device *DeviceScenario, rxBytes, txBytes uint64) []interface{} {
+	// Выбираем протокол по весам и случайный порт
+	proto := pickProtocol(rng, device.Protocols)
+	var port uint16
+	if len(proto.Ports) > 0 {
+		port = proto.Ports[rng.Intn(len(proto.Ports))]
 	}
 
-	// Выбираем случайный протокол
-	protoIdx := g.rand.Intn(len(protocols))
-	proto := protocols[protoIdx]
-
-	// Выбираем случайный порт
-	port := proto.ports[g.rand.Intn(len(proto.ports))]
-
 	// Количество пакетов примерно пропорционально байтам
-	rxPkts := rxBytes / uint64(g.rand.Intn(1000)+500)
-	txPkts := txBytes / uint64(g.rand.Intn(1000)+500)
+	rxPkts := rxBytes / uint64(rng.Intn(1000)+500)
+	txPkts := txBytes / uint64(rng.Intn(1000)+500)
 	if rxPkts == 0 && rxBytes > 0 {
 		rxPkts = 1
 	}
@@ -166,84 +162,67 @@ func (g *Generator) generateRecordWithTraffic(device Device, rxBytes, txBytes ui
 	}
 
 	// Количество соединений
-	conns := uint64(g.rand.Intn(100) + 1)
+	conns := uint64(rng.Intn(100) + 1)
 
 	return []interface{}{
-		4,            // family (IPv4)
-		proto.name,   // proto
-		port,         // port
-		device.MAC,   // mac
-		device.IP,    // ip
-		conns,        // conns
-		rxBytes,      // rx_bytes
-		rxPkts,       // rx_pkts
-		txBytes,      // tx_bytes
-		txPkts,       // tx_pkts
-		nil,          // layer7
+		4,                            // family (IPv4)
+		strings.ToUpper(proto.Proto), // proto
+		port,                         // port
+		device.MAC,                   // mac
+		device.IP,                    // ip
+		conns,                        // conns
+		rxBytes,                      // rx_bytes
+		rxPkts,                       // rx_pkts
+		txBytes,                      // tx_bytes
+		txPkts,                       // tx_pkts
+		nil,                          // layer7
 	}
 }
 
-// generateNormalDeviceTraffic генерирует трафик для обычного устройства
-// Распределение: 2 ГБ = 70%, 0 ГБ = 15%, 15 ГБ = 15%
-func (g *Generator) generateNormalDeviceTraffic() uint64 {
-	dice := g.rand.Float64()
-
-	if dice < 0.15 {
-		// 15% - нулевой трафик
-		return 0
-	} else if dice < 0.85 {
-		// 70% - около 2 ГБ (с вариацией от 1 до 3 ГБ)
-		baseTraffic := 2 * 1024 * 1024 * 1024 // 2 ГБ
-		variation := g.rand.Float64()*2 - 1   // от -1 до +1 ГБ
-		traffic := float64(baseTraffic) + variation*1024*1024*1024
-		if traffic < 0 {
-			traffic = float64(g.rand.Intn(100*1024*1024) + 1024) // минимум 1 КБ
-		}
-		return uint64(traffic)
-	} else {
-		// 15% - около 15 ГБ (с вариацией от 10 до 15 ГБ)
-		baseTraffic := 15 * 1024 * 1024 * 1024 // 15 ГБ
-		variation := g.rand.Float64() * 5      // от 0 до 5 ГБ вниз
-		traffic := float64(baseTraffic) - variation*1024*1024*1024
-		return uint64(traffic)
+// pickProtocol выбирает протокол с учётом весов
+func pickProtocol(rng *rand.Rand, protocols []ProtocolShare) ProtocolShare {
+	var total float64
+	for _, share := range protocols {
+		total += share.Weight
+	}
+	if total <= 0 {
+		return protocols[rng.Intn(len(protocols))]
 	}
-}
 
-// generateNormalDeviceTrafficWithRatio генерирует трафик с учетом коэффициента
-func (g *Generator) generateNormalDeviceTrafficWithRatio(ratio float64) uint64 {
-	dice := g.rand.Float64()
-
-	if dice < 0.15 {
-		return 0
-	} else if dice < 0.85 {
-		// 70% - около 2 ГБ с учетом ratio
-		baseTraffic := 2 * 1024 * 1024 * 1024
-		variation := g.rand.Float64()*2 - 1
-		traffic := (float64(baseTraffic) + variation*1024*1024*1024) * ratio
-		if traffic < 0 {
-			traffic = float64(g.rand.Intn(100*1024*1024) + 1024)
+	dice := rng.Float64() * total
+	for _, share := range protocols {
+		if dice < share.Weight {
+			return share
 		}
-		return uint64(traffic)
-	} else {
-		// 15% - около 15 ГБ с учетом ratio
-		baseTraffic := 15 * 1024 * 1024 * 1024
-		variation := g.rand.Float64() * 5
-		traffic := (float64(baseTraffic) - variation*1024*1024*1024) * ratio
-		return uint64(traffic)
+		dice -= share.Weight
 	}
+	return protocols[len(protocols)-1]
 }
 
-// generateIoTTraffic генерирует трафик для IoT устройства (100 KB - 2 MB)
-func (g *Generator) generateIoTTraffic() uint64 {
-	minTraffic := 100 * 1024      // 100 KB
-	maxTraffic := 2 * 1024 * 1024 // 2 MB
+// generateVolume генерирует суточный объём по распределению устройства:
+// сначала вариант выбирается по весу, затем значение внутри [Min, Max]
+func generateVolume(rng *rand.Rand, buckets []VolumeBucket) uint64 {
+	var total float64
+	for _, bucket := range buckets {
+		total += bucket.Weight
+	}
 
-	// Используем экспоненциальное распределение - большинство ближе к минимуму
-	uniform := g.rand.Float64()
-	weighted := math.Pow(uniform, 2.0) // степень 2 для смещения к меньшим значениям
+	chosen := buckets[len(buckets)-1]
+	dice := rng.Float64() * total
+	for _, bucket := range buckets {
+		if dice < bucket.Weight {
+			chosen = bucket
+			break
+		}
+		dice -= bucket.Weight
+	}
 
-	traffic := float64(minTraffic) + weighted*float64(maxTraffic-minTraffic)
-	return uint64(traffic)
+	// Степень skew смещает значения к минимуму
+	uniform := rng.Float64()
+	if chosen.Skew > 0 {
+		uniform = math.Pow(uniform, chosen.Skew)
+	}
+	return uint64(float64(chosen.Min) + uniform*float64(chosen.Max-chosen.Min))
 }
 
 // GetAllDates возвращает все даты в диапазоне
@@ -0,0 +1,164 @@
+package demo
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(value string) time.Time {
+	t, _ := time.Parse("2006-01-02", value)
+	return t
+}
+
+// dayDevices возвращает MAC устройств с трафиком за день
+func dayDevices(g *Generator, day string) map[string]bool {
+	devices := make(map[string]bool)
+	for _, row := range g.GenerateForDate(date(day)).Data {
+		devices[row[3].(string)] = true
+	}
+	return devices
+}
+
+func TestGenerator_SameSeedSameData(t *testing.T) {
+	a := NewGeneratorWithSeed(42, nil)
+	b := NewGeneratorWithSeed(42, nil)
+
+	// Порядок генерации не влияет на данные дня
+	first := a.GenerateForDate(date("2024-05-01"))
+	b.GenerateForDate(date("2024-05-02"))
+	second := b.GenerateForDate(date("2024-05-01"))
+
+	if len(first.Data) == 0 {
+		t.Fatal("Expected some traffic")
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Error("Same seed and date must produce identical data")
+	}
+
+	other := NewGeneratorWithSeed(43, nil).GenerateForDate(date("2024-05-01"))
+	if reflect.DeepEqual(first, other) {
+		t.Error("Different seeds must produce different data")
+	}
+}
+
+func TestGenerator_WriteDayReproducible(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	day := date("2024-05-01")
+
+	if err := WriteDay(dirA, day, NewGeneratorWithSeed(7, nil).GenerateForDate(day)); err != nil {
+		t.Fatalf("WriteDay failed: %v", err)
+	}
+	if err := WriteDay(dirB, day, NewGeneratorWithSeed(7, nil).GenerateForDate(day)); err != nil {
+		t.Fatalf("WriteDay failed: %v", err)
+	}
+
+	a, _ := os.ReadFile(filepath.Join(dirA, "20240501.db.gz"))
+	b, _ := os.ReadFile(filepath.Join(dirB, "20240501.db.gz"))
+
+	if len(a) == 0 || !bytes.Equal(a, b) {
+		t.Errorf("Expected identical files, got %d and %d bytes", len(a), len(b))
+	}
+}
+
+func TestLoadScenario_Example(t *testing.T) {
+	scenario, err := LoadScenario(filepath.Join("..", "..", "demo-scenario.yaml.example"))
+	if err != nil {
+		t.Fatalf("LoadScenario failed: %v", err)
+	}
+	g := NewGeneratorWithSeed(1, scenario)
+
+	// Slumber Party: 16 гостевых телефонов + постоянные устройства
+	party := dayDevices(g, "2024-12-31")
+	if len(party) < 20 {
+		t.Errorf("Expected 20+ devices on 2024-12-31, got %d", len(party))
+	}
+	if !party["02:00:00:00:00:10"] {
+		t.Error("Expected the 16th guest phone on 2024-12-31")
+	}
+	if dayDevices(g, "2025-01-01")["02:00:00:00:00:01"] {
+		t.Error("Guest phones must leave after until")
+	}
+	if g.Names()["02:00:00:00:00:03"] != "Guest Phone #3" {
+		t.Errorf("Unexpected clone name %q", g.Names()["02:00:00:00:00:03"])
+	}
+
+	// Miss me?: телефона нет в сети с 1 июня по 15 сентября
+	for _, day := range []string{"2024-06-01", "2024-07-15", "2024-09-15"} {
+		if dayDevices(g, day)["12:34:56:78:9a:bc"] {
+			t.Errorf("Travel phone must be absent on %s", day)
+		}
+	}
+
+	// Устройство вне from/until не появляется
+	if dayDevices(g, "2024-02-28")["fe:dc:ba:98:76:54"] {
+		t.Error("Old TV must not appear before from")
+	}
+}
+
+func TestLoadScenario_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"bad mac":    "devices:\n  - {mac: zz, ip: 10.0.0.1}\n",
+		"bad size":   "devices:\n  - {mac: '00:00:00:00:00:01', ip: 10.0.0.1, volume: [{weight: 1, max: 2XB}]}\n",
+		"bad proto":  "devices:\n  - {mac: '00:00:00:00:00:01', ip: 10.0.0.1, protocols: [{proto: SCTP, weight: 1}]}\n",
+		"duplicate":  "devices:\n  - {mac: '00:00:00:00:00:01', ip: 10.0.0.1, count: 2}\n  - {mac: '00:00:00:00:00:02', ip: 10.0.0.5}\n",
+		"bad hours":  "devices:\n  - {mac: '00:00:00:00:00:01', ip: 10.0.0.1, active_hours: [20, 8]}\n",
+		"no devices": "empty_days: 0.1\n",
+		"bad absent": "devices:\n  - {mac: '00:00:00:00:00:01', ip: 10.0.0.1, absent: [{from: 2024-02-01, to: 2024-01-01}]}\n",
+		"bad date":   "devices:\n  - {mac: '00:00:00:00:00:01', ip: 10.0.0.1, from: 01.02.2024}\n",
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scenario.yaml")
+			os.WriteFile(path, []byte(content), 0644)
+			if _, err := LoadScenario(path); err == nil {
+				t.Errorf("Expected error for %s", strings.TrimSpace(content))
+			}
+		})
+	}
+}
+
+func TestLiveDay_ActiveHours(t *testing.T) {
+	scenario := &Scenario{Devices: []DeviceScenario{
+		{MAC: "00:00:00:00:00:01", IP: "10.0.0.1", ActiveHours: [2]int{8, 20}, Volume: []VolumeBucket{{Weight: 1, Min: 1 << 30, Max: 1 << 30}}},
+		{MAC: "00:00:00:00:00:02", IP: "10.0.0.2", Volume: []VolumeBucket{{Weight: 1, Min: 1 << 30, Max: 1 << 30}}},
+	}}
+	if err := scenario.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+
+	progress := NewGeneratorWithSeed(1, scenario).dayProgress(time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC))
+	if progress["00:00:00:00:00:01"] != 0 || progress["00:00:00:00:00:02"] != 0.25 {
+		t.Errorf("Unexpected progress at 06:00: %v", progress)
+	}
+
+	progress = NewGeneratorWithSeed(1, scenario).dayProgress(time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC))
+	if progress["00:00:00:00:00:01"] != 0.5 {
+		t.Errorf("Unexpected progress at 14:00: %v", progress)
+	}
+}
+
+func TestLiveDay_RunWaitsForFirstTick(t *testing.T) {
+	dir := t.TempDir()
+	live := NewLiveDay(NewGeneratorWithSeed(1, nil), dir)
+
+	// Начальный файл пишет вызывающий - Run не должен перезаписать его сразу
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		live.Run(time.Hour, stop)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	<-done
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Run must not write before the first tick, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,362 @@
+package demo
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario описывает устройства demo сети. Загружается из YAML
+// (--demo-scenario), без файла используется DefaultScenario.
+type Scenario struct {
+	EmptyDays float64          `yaml:"empty_days"` // доля дней без трафика (0..1)
+	Devices   []DeviceScenario `yaml:"devices"`
+}
+
+// DeviceScenario - профиль устройства в сценарии
+type DeviceScenario struct {
+	MAC   string `yaml:"mac"`
+	IP    string `yaml:"ip"`
+	Name  string `yaml:"name"`
+	IoT   bool   `yaml:"iot"`
+	Count int    `yaml:"count"` // размножить устройство: MAC и IP увеличиваются на 1
+
+	Volume      []VolumeBucket  `yaml:"volume"`       // распределение суточного download
+	UploadRatio [2]float64      `yaml:"upload_ratio"` // upload = download * [min, max]
+	Records     [2]int          `yaml:"records"`      // записей (протокол/порт) в день [min, max]
+	Protocols   []ProtocolShare `yaml:"protocols"`
+	ActiveHours [2]int          `yaml:"active_hours"` // часы активности [from, to), для растущего сегодняшнего файла
+
+	From   string     `yaml:"from"`   // YYYY-MM-DD, первый день в сети
+	Until  string     `yaml:"until"`  // YYYY-MM-DD, последний день в сети
+	Absent []DateSpan `yaml:"absent"` // периоды отсутствия
+	from   time.Time
+	until  time.Time
+}
+
+// VolumeBucket - вариант суточного объёма с весом.
+// Skew > 1 смещает значения к Min (как у IoT устройств).
+type VolumeBucket struct {
+	Weight float64 `yaml:"weight"`
+	Min    Size    `yaml:"min"`
+	Max    Size    `yaml:"max"`
+	Skew   float64 `yaml:"skew"`
+}
+
+// ProtocolShare - протокол с портами и весом в миксе устройства
+type ProtocolShare struct {
+	Proto  string   `yaml:"proto"`
+	Ports  []uint16 `yaml:"ports"`
+	Weight float64  `yaml:"weight"`
+}
+
+// DateSpan - период дат включительно
+type DateSpan struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	from time.Time
+	to   time.Time
+}
+
+// Size - объём в байтах, в YAML можно писать "100KB", "2GB", "1.5TB"
+type Size uint64
+
+var sizeUnits = []struct {
+	suffix string
+	factor float64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+}
+
+// UnmarshalYAML разбирает число байт или строку с единицей измерения
+func (s *Size) UnmarshalYAML(node *yaml.Node) error {
+	value := strings.ToUpper(strings.TrimSpace(node.Value))
+	factor := 1.0
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return fmt.Errorf("invalid size %q", node.Value)
+	}
+	*s = Size(number * factor)
+	return nil
+}
+
+// LoadScenario читает сценарий из YAML файла
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+
+	if err := scenario.prepare(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+// prepare проверяет сценарий, подставляет значения по умолчанию
+// и разворачивает устройства с count > 1
+func (s *Scenario) prepare() error {
+	if s.EmptyDays < 0 || s.EmptyDays >= 1 {
+		return fmt.Errorf("empty_days must be in [0, 1)")
+	}
+	if len(s.Devices) == 0 {
+		return fmt.Errorf("no devices")
+	}
+
+	devices := make([]DeviceScenario, 0, len(s.Devices))
+	seen := make(map[string]bool)
+	for i := range s.Devices {
+		d := &s.Devices[i]
+		if err := d.prepare(); err != nil {
+			return fmt.Errorf("device %d (%s): %w", i+1, d.Name, err)
+		}
+
+		for _, clone := range d.expand() {
+			if seen[clone.MAC] {
+				return fmt.Errorf("duplicate mac %s", clone.MAC)
+			}
+			seen[clone.MAC] = true
+			devices = append(devices, clone)
+		}
+	}
+
+	s.Devices = devices
+	return nil
+}
+
+func (d *DeviceScenario) prepare() error {
+	mac, err := net.ParseMAC(d.MAC)
+	if err != nil || len(mac) != 6 {
+		return fmt.Errorf("invalid mac %q", d.MAC)
+	}
+	d.MAC = mac.String()
+
+	if ip := net.ParseIP(d.IP); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("invalid ipv4 address %q", d.IP)
+	}
+	if d.Name == "" {
+		d.Name = d.MAC
+	}
+	if d.Count == 0 {
+		d.Count = 1
+	}
+	if d.Count < 0 || d.Count > 250 {
+		return fmt.Errorf("count must be in [1, 250]")
+	}
+
+	defaults := defaultProfile(d.IoT)
+	if len(d.Volume) == 0 {
+		d.Volume = defaults.Volume
+	}
+	if d.UploadRatio == [2]float64{} {
+		d.UploadRatio = defaults.UploadRatio
+	}
+	if d.Records == [2]int{} {
+		d.Records = defaults.Records
+	}
+	if len(d.Protocols) == 0 {
+		d.Protocols = defaults.Protocols
+	}
+	if d.ActiveHours == [2]int{} {
+		d.ActiveHours = [2]int{0, 24}
+	}
+
+	var total float64
+	for _, bucket := range d.Volume {
+		if bucket.Weight < 0 || bucket.Max < bucket.Min {
+			return fmt.Errorf("invalid volume bucket %+v", bucket)
+		}
+		total += bucket.Weight
+	}
+	if total <= 0 {
+		return fmt.Errorf("volume weights must be positive")
+	}
+
+	for _, share := range d.Protocols {
+		if !demoProtocols[strings.ToUpper(share.Proto)] {
+			return fmt.Errorf("unknown protocol %q", share.Proto)
+		}
+		if share.Weight < 0 {
+			return fmt.Errorf("negative weight for %s", share.Proto)
+		}
+	}
+
+	if d.UploadRatio[0] < 0 || d.UploadRatio[1] < d.UploadRatio[0] {
+		return fmt.Errorf("invalid upload_ratio %v", d.UploadRatio)
+	}
+	if d.Records[0] < 1 || d.Records[1] < d.Records[0] {
+		return fmt.Errorf("invalid records %v", d.Records)
+	}
+	if d.ActiveHours[0] < 0 || d.ActiveHours[1] > 24 || d.ActiveHours[1] <= d.ActiveHours[0] {
+		return fmt.Errorf("invalid active_hours %v", d.ActiveHours)
+	}
+
+	if d.from, err = parseScenarioDate(d.From); err != nil {
+		return fmt.Errorf("invalid from: %w", err)
+	}
+	if d.until, err = parseScenarioDate(d.Until); err != nil {
+		return fmt.Errorf("invalid until: %w", err)
+	}
+	for i := range d.Absent {
+		span := &d.Absent[i]
+		if span.from, err = parseScenarioDate(span.From); err != nil || span.from.IsZero() {
+			return fmt.Errorf("invalid absent from %q", span.From)
+		}
+		if span.to, err = parseScenarioDate(span.To); err != nil || span.to.IsZero() || span.to.Before(span.from) {
+			return fmt.Errorf("invalid absent to %q", span.To)
+		}
+	}
+
+	return nil
+}
+
+// expand возвращает count копий устройства с последовательными MAC и IP
+func (d *DeviceScenario) expand() []DeviceScenario {
+	if d.Count == 1 {
+		return []DeviceScenario{*d}
+	}
+
+	mac, _ := net.ParseMAC(d.MAC)
+	ip := net.ParseIP(d.IP).To4()
+
+	clones := make([]DeviceScenario, d.Count)
+	for i := range clones {
+		clone := *d
+		clone.Count = 1
+		clone.MAC = offsetBytes(mac, i).String()
+		clone.IP = net.IP(offsetBytes(ip, i)).String()
+		clone.Name = fmt.Sprintf("%s #%d", d.Name, i+1)
+		clones[i] = clone
+	}
+	return clones
+}
+
+// offsetBytes прибавляет n к адресу как к big endian числу
+func offsetBytes(addr []byte, n int) net.HardwareAddr {
+	result := make([]byte, len(addr))
+	copy(result, addr)
+	carry := n
+	for i := len(result) - 1; i >= 0 && carry > 0; i-- {
+		sum := int(result[i]) + carry
+		result[i] = byte(sum)
+		carry = sum >> 8
+	}
+	return result
+}
+
+// activeOn сообщает, есть ли устройство в сети в этот день
+func (d *DeviceScenario) activeOn(day time.Time) bool {
+	if !d.from.IsZero() && day.Before(d.from) {
+		return false
+	}
+	if !d.until.IsZero() && day.After(d.until) {
+		return false
+	}
+	for _, span := range d.Absent {
+		if !day.Before(span.from) && !day.After(span.to) {
+			return false
+		}
+	}
+	return true
+}
+
+// activeFraction - доля часов активности устройства, прошедшая к моменту now
+func (d *DeviceScenario) activeFraction(now time.Time) float64 {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	hours := now.Sub(midnight).Hours()
+
+	from, to := float64(d.ActiveHours[0]), float64(d.ActiveHours[1])
+	switch {
+	case hours <= from:
+		return 0
+	case hours >= to:
+		return 1
+	}
+	return (hours - from) / (to - from)
+}
+
+func parseScenarioDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.UTC)
+}
+
+// demoProtocols - протоколы, которые умеет генерировать demo
+var demoProtocols = map[string]bool{"TCP": true, "UDP": true, "ICMP": true}
+
+// defaultProfile - профиль трафика по умолчанию для обычных и IoT устройств
+func defaultProfile(iot bool) DeviceScenario {
+	if iot {
+		// 100 KB - 2 MB в день, большинство ближе к минимуму
+		return DeviceScenario{
+			Volume:      []VolumeBucket{{Weight: 1, Min: 100 << 10, Max: 2 << 20, Skew: 2}},
+			UploadRatio: [2]float64{0.5, 1.5},
+			Records:     [2]int{1, 3},
+			Protocols:   defaultProtocols(),
+		}
+	}
+
+	// 15% - без трафика, 70% - около 2 ГБ, 15% - 10-15 ГБ
+	return DeviceScenario{
+		Volume: []VolumeBucket{
+			{Weight: 0.15},
+			{Weight: 0.70, Min: 1 << 30, Max: 3 << 30},
+			{Weight: 0.15, Min: 10 << 30, Max: 15 << 30},
+		},
+		UploadRatio: [2]float64{0.3, 0.9},
+		Records:     [2]int{5, 14},
+		Protocols:   defaultProtocols(),
+	}
+}
+
+func defaultProtocols() []ProtocolShare {
+	return []ProtocolShare{
+		{Proto: "TCP", Ports: []uint16{80, 443, 8080, 22, 3389, 5432, 3306}, Weight: 1},
+		{Proto: "UDP", Ports: []uint16{53, 123, 1194, 500, 4500}, Weight: 1},
+		{Proto: "ICMP", Ports: []uint16{0}, Weight: 1},
+	}
+}
+
+// DefaultScenario - 4 обычных устройства (до 15 ГБ/день) и 6 IoT (до 2 МБ/день)
+func DefaultScenario() *Scenario {
+	scenario := &Scenario{
+		EmptyDays: 0.05,
+		Devices: []DeviceScenario{
+			{MAC: "00:11:22:33:44:55", IP: "192.168.1.10", Name: "Desktop PC"},
+			{MAC: "aa:bb:cc:dd:ee:ff", IP: "192.168.1.20", Name: "Laptop"},
+			{MAC: "12:34:56:78:9a:bc", IP: "192.168.1.30", Name: "iPhone"},
+			{MAC: "fe:dc:ba:98:76:54", IP: "192.168.1.40", Name: "Smart TV"},
+
+			{MAC: "11:22:33:44:55:66", IP: "192.168.1.50", Name: "Smart Thermostat", IoT: true},
+			{MAC: "aa:11:bb:22:cc:33", IP: "192.168.1.60", Name: "Smart Light", IoT: true},
+			{MAC: "ff:ee:dd:cc:bb:aa", IP: "192.168.1.70", Name: "Security Camera", IoT: true},
+			{MAC: "99:88:77:66:55:44", IP: "192.168.1.80", Name: "Smart Speaker", IoT: true},
+			{MAC: "bb:aa:99:88:77:66", IP: "192.168.1.90", Name: "Smart Lock", IoT: true},
+			{MAC: "cc:dd:ee:ff:00:11", IP: "192.168.1.100", Name: "Smart Plug", IoT: true},
+		},
+	}
+
+	if err := scenario.prepare(); err != nil {
+		panic(err)
+	}
+	return scenario
+}
@@ -0,0 +1,133 @@
+package demo
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"nlbw-ui/internal/converter"
+)
+
+// intervalDaily - тип интервала в заголовке базы: один файл на день
+const intervalDaily = 2
+
+// WriteDay пишет данные дня в dir/YYYYMMDD.db.gz в формате nlbwmon
+func WriteDay(dir string, date time.Time, data *converter.TrafficData) error {
+	records, err := converter.RecordsFromTrafficData(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode demo data: %w", err)
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+
+	// Время последней записи: для прошедших дней - конец дня, чтобы
+	// при одном seed файлы совпадали побайтно
+	committed := time.Now()
+	if end := day.AddDate(0, 0, 1); end.Before(committed) {
+		committed = end
+	}
+
+	db := converter.Database{
+		Timestamp: uint32(committed.Unix()),
+		Interval: converter.Interval{
+			Type:  intervalDaily,
+			Base:  uint64(day.Unix()),
+			Value: 1,
+		},
+	}
+
+	path := filepath.Join(dir, FormatDateForFilename(date)+".db.gz")
+	return converter.New().WriteFile(path, db, records)
+}
+
+// LiveDay имитирует работающий nlbwmon: сегодняшний файл периодически
+// перезаписывается со счётчиками, растущими вместе со временем суток.
+// Файл проходит обычный путь scanner -> converter -> cache.
+type LiveDay struct {
+	generator *Generator
+	dir       string
+	now       func() time.Time
+
+	date   string                 // YYYYMMDD текущего дня
+	target *converter.TrafficData // трафик за полный день
+}
+
+// NewLiveDay создаёт имитацию сегодняшнего дня в dir
+func NewLiveDay(generator *Generator, dir string) *LiveDay {
+	return &LiveDay{
+		generator: generator,
+		dir:       dir,
+		now:       time.Now,
+	}
+}
+
+// Update перезаписывает сегодняшний файл. После полуночи вчерашний
+// файл дописывается полностью и начинается новый день.
+func (l *LiveDay) Update() error {
+	now := l.now()
+	today := FormatDateForFilename(now)
+
+	if today != l.date {
+		if l.target != nil {
+			yesterday, _ := time.ParseInLocation("20060102", l.date, time.Local)
+			if err := WriteDay(l.dir, yesterday, l.target); err != nil {
+				return err
+			}
+		}
+		l.date = today
+		l.target = l.generator.GenerateForDate(now)
+	}
+
+	// Трафик устройства растёт только в его часы активности
+	return WriteDay(l.dir, now, scaleTraffic(l.target, l.generator.dayProgress(now)))
+}
+
+// Run обновляет файл с интервалом до закрытия stop. Первое обновление -
+// через interval: начальный файл пишет вызывающий через Update,
+// чтобы сразу увидеть ошибку.
+func (l *LiveDay) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := l.Update(); err != nil {
+			fmt.Printf("Demo live day error: %v\n", err)
+		}
+	}
+}
+
+// scaleTraffic возвращает копию данных со счётчиками, умноженными на
+// долю прошедшего дня устройства (по MAC). Строки, где всё обнулилось, отбрасываются.
+func scaleTraffic(data *converter.TrafficData, progress map[string]float64) *converter.TrafficData {
+	result := &converter.TrafficData{
+		Columns: data.Columns,
+		Data:    make([][]interface{}, 0, len(data.Data)),
+	}
+
+	for _, row := range data.Data {
+		scaled := make([]interface{}, len(row))
+		copy(scaled, row)
+		mac, _ := row[3].(string)
+		fraction := progress[mac]
+
+		var total uint64
+		for i := 5; i <= 9 && i < len(row); i++ {
+			if value, ok := row[i].(uint64); ok {
+				scaled[i] = uint64(float64(value) * fraction)
+				total += scaled[i].(uint64)
+			}
+		}
+
+		if total > 0 {
+			result.Data = append(result.Data, scaled)
+		}
+	}
+
+	return result
+}
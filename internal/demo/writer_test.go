@@ -0,0 +1,63 @@
+package demo
+
+import (
+	"path/filepath"
+	"testing"
+
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/converter"
+	"nlbw-ui/internal/scanner"
+)
+
+// totals суммирует rx/tx байты по всем строкам
+func totals(data *converter.TrafficData) (rx, tx uint64) {
+	for _, row := range data.Data {
+		rx += row[6].(uint64)
+		tx += row[8].(uint64)
+	}
+	return rx, tx
+}
+
+// Demo данные проходят тот же путь, что и файлы nlbwmon: scanner -> converter -> cache
+func TestWriteDay_ScannedIntoCache(t *testing.T) {
+	dir := t.TempDir()
+	generator := NewGeneratorWithSeed(11, nil)
+
+	dateRange, err := ParseDateRange("01.05.2024-03.05.2024")
+	if err != nil {
+		t.Fatalf("ParseDateRange failed: %v", err)
+	}
+	for _, day := range dateRange.GetAllDates() {
+		if err := WriteDay(dir, day, generator.GenerateForDate(day)); err != nil {
+			t.Fatalf("WriteDay failed: %v", err)
+		}
+	}
+
+	c := cache.New()
+	s := scanner.New(dir)
+	s.OnNewFile(func(path string) {
+		if err := c.LoadFile("demo", path); err != nil {
+			t.Errorf("LoadFile %s failed: %v", path, err)
+		}
+	})
+	if _, err := s.Scan(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if files := c.GetAll(); len(files) != 3 {
+		t.Fatalf("Expected 3 cached days, got %d", len(files))
+	}
+	for _, day := range dateRange.GetAllDates() {
+		key := cache.Key("demo", filepath.Join(dir, FormatDateForFilename(day)+".db.gz"))
+		cached, ok := c.Get(key)
+		if !ok {
+			t.Fatalf("Day %s not found in cache", FormatDateISO(day))
+		}
+
+		wantRx, wantTx := totals(generator.GenerateForDate(day))
+		gotRx, gotTx := totals(cached)
+		if wantRx == 0 || gotRx != wantRx || gotTx != wantTx {
+			t.Errorf("%s: expected rx=%d tx=%d, got rx=%d tx=%d", FormatDateISO(day), wantRx, wantTx, gotRx, gotTx)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Типы событий, отправляемых клиентам
+const (
+	TypeFileLoaded          = "file_loaded"
+	TypeFileModified        = "file_modified"
+	TypeDayUpdated          = "day_updated"
+	TypeDeviceNew           = "device_new"
+	TypeAchievementUnlocked = "achievement_unlocked"
+	TypeAnomaly             = "anomaly"
+)
+
+// subscriberBuffer - сколько событий может накопиться у медленного клиента
+const subscriberBuffer = 64
+
+// Event - одно событие для SSE потока
+type Event struct {
+	Type string
+	Data json.RawMessage
+}
+
+// Hub рассылает события всем подписчикам
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewHub создаёт пустой hub
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe регистрирует нового подписчика.
+// Возвращённый канал закрывается после Unsubscribe.
+func (h *Hub) Subscribe() chan Event {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe отписывает и закрывает канал подписчика
+func (h *Hub) Unsubscribe(ch chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// HasSubscribers сообщает, есть ли сейчас слушатели
+func (h *Hub) HasSubscribers() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers) > 0
+}
+
+// Publish сериализует payload и рассылает событие.
+// Медленные клиенты с переполненным буфером пропускают событие.
+func (h *Hub) Publish(eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	event := Event{Type: eventType, Data: data}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHub_PublishSubscribe(t *testing.T) {
+	hub := NewHub()
+	if hub.HasSubscribers() {
+		t.Error("New hub must have no subscribers")
+	}
+
+	a, b := hub.Subscribe(), hub.Subscribe()
+	if !hub.HasSubscribers() {
+		t.Error("Expected subscribers")
+	}
+
+	if err := hub.Publish(TypeDayUpdated, map[string]string{"date": "2024-01-01"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	for _, ch := range []chan Event{a, b} {
+		event := <-ch
+		var payload map[string]string
+		if err := json.Unmarshal(event.Data, &payload); err != nil {
+			t.Fatalf("Invalid payload: %v", err)
+		}
+		if event.Type != TypeDayUpdated || payload["date"] != "2024-01-01" {
+			t.Errorf("Unexpected event: %s %s", event.Type, event.Data)
+		}
+	}
+
+	if err := hub.Publish(TypeDayUpdated, func() {}); err == nil {
+		t.Error("Expected error for a payload that is not JSON")
+	}
+}
+
+func TestHub_Unsubscribe(t *testing.T) {
+	hub := NewHub()
+	ch := hub.Subscribe()
+
+	hub.Unsubscribe(ch)
+	if _, ok := <-ch; ok {
+		t.Error("Channel must be closed after Unsubscribe")
+	}
+	if hub.HasSubscribers() {
+		t.Error("Expected no subscribers after Unsubscribe")
+	}
+
+	// Повторная отписка и публикация без подписчиков не паникуют
+	hub.Unsubscribe(ch)
+	hub.Publish(TypeFileLoaded, nil)
+}
+
+func TestHub_SlowSubscriberDropsEvents(t *testing.T) {
+	hub := NewHub()
+	slow := hub.Subscribe()
+	fast := hub.Subscribe()
+
+	received := 0
+	for i := 0; i < subscriberBuffer+10; i++ {
+		// Publish не должен блокироваться на переполненном буфере
+		hub.Publish(TypeFileModified, i)
+		<-fast
+		received++
+	}
+
+	if received != subscriberBuffer+10 {
+		t.Errorf("Fast subscriber must receive every event, got %d", received)
+	}
+	if len(slow) != subscriberBuffer {
+		t.Errorf("Expected %d buffered events for the slow subscriber, got %d", subscriberBuffer, len(slow))
+	}
+
+	var first int
+	json.Unmarshal((<-slow).Data, &first)
+	if first != 0 {
+		t.Errorf("Slow subscriber must keep the oldest events, got %d first", first)
+	}
+}
@@ -0,0 +1,301 @@
+package live
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/converter"
+)
+
+// serveFixture поднимает фейковый управляющий сокет nlbwmon,
+// который на команду dump отдаёт записанный дамп
+func serveFixture(t *testing.T, fixture string) string {
+	t.Helper()
+
+	payload, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "nlbwmon.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			buf := make([]byte, 64)
+			n, _ := conn.Read(buf)
+			if string(buf[:n]) == "dump" {
+				conn.Write(payload)
+			}
+			conn.Close()
+		}
+	}()
+
+	return socketPath
+}
+
+func loadJSONFixture(t *testing.T) *converter.TrafficData {
+	t.Helper()
+
+	file, err := os.Open("testdata/nlbw.json")
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	data, err := converter.ParseJSON(file)
+	if err != nil {
+		t.Fatalf("ParseJSON failed: %v", err)
+	}
+	return data
+}
+
+func findRow(data *converter.TrafficData, proto string, port uint16, mac string) []interface{} {
+	for _, row := range data.Data {
+		if row[1] == proto && row[2] == port && row[3] == mac {
+			return row
+		}
+	}
+	return nil
+}
+
+func TestParseJSON_Fixture(t *testing.T) {
+	data := loadJSONFixture(t)
+
+	if len(data.Data) != 4 {
+		t.Fatalf("Expected 4 rows, got %d", len(data.Data))
+	}
+
+	row := findRow(data, "TCP", 443, "aa:bb:cc:dd:ee:01")
+	if row == nil {
+		t.Fatal("TCP/443 row not found")
+	}
+	if row[0] != 4 || row[4] != "192.168.1.10" {
+		t.Errorf("Unexpected family/ip: %v %v", row[0], row[4])
+	}
+	if row[6] != uint64(3000000) || row[8] != uint64(180000) {
+		t.Errorf("Unexpected rx/tx bytes: %v %v", row[6], row[8])
+	}
+	// В выгрузке нет layer7 - заполняется по таблице сервисов
+	if row[10] != "HTTPS" {
+		t.Errorf("Expected HTTPS layer7, got %v", row[10])
+	}
+}
+
+func TestSocketSource_Fetch(t *testing.T) {
+	source := &SocketSource{Path: serveFixture(t, "testdata/dump.bin"), Timeout: 2 * time.Second}
+
+	data, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	if len(data.Data) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(data.Data))
+	}
+
+	row := findRow(data, "TCP", 443, "aa:bb:cc:dd:ee:02")
+	if row == nil {
+		t.Fatal("IPv6 row not found")
+	}
+	if row[0] != 6 || row[4] != "2001:db8::2" {
+		t.Errorf("Unexpected family/ip: %v %v", row[0], row[4])
+	}
+
+	row = findRow(data, "UDP", 53, "aa:bb:cc:dd:ee:01")
+	if row == nil || row[4] != "192.168.1.10" || row[5] != uint64(40) {
+		t.Errorf("Unexpected DNS row: %v", row)
+	}
+}
+
+func TestSocketSource_ConnectionError(t *testing.T) {
+	source := &SocketSource{Path: filepath.Join(t.TempDir(), "missing.sock")}
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Error("Expected error for missing socket")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	source := &SocketSource{Path: serveFixture(t, "testdata/dump.bin"), Timeout: 2 * time.Second}
+	disk, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	liveData := loadJSONFixture(t)
+
+	merged := Merge(disk, liveData)
+
+	if len(merged.Data) != 4 {
+		t.Fatalf("Expected 4 merged rows, got %d", len(merged.Data))
+	}
+
+	// Счётчики в памяти больше - берутся они
+	row := findRow(merged, "TCP", 443, "aa:bb:cc:dd:ee:01")
+	if row[5] != uint64(150) || row[6] != uint64(3000000) {
+		t.Errorf("Expected live counters, got conns=%v rx=%v", row[5], row[6])
+	}
+
+	// Запись только из памяти добавляется
+	if findRow(merged, "UDP", 123, "aa:bb:cc:dd:ee:03") == nil {
+		t.Error("Live-only row missing from merge")
+	}
+
+	// Исходные данные не изменяются
+	if findRow(disk, "TCP", 443, "aa:bb:cc:dd:ee:01")[5] != uint64(120) {
+		t.Error("Merge modified disk data")
+	}
+}
+
+type staticSource struct {
+	data *converter.TrafficData
+}
+
+func (s *staticSource) Fetch(ctx context.Context) (*converter.TrafficData, error) {
+	return s.data, nil
+}
+
+func TestPoller_Poll(t *testing.T) {
+	dataDir := t.TempDir()
+	c := cache.New()
+
+	poller := NewPoller(&staticSource{data: loadJSONFixture(t)}, c, "main", func() string { return dataDir }, time.Second)
+	poller.now = func() time.Time { return time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local) }
+
+	// Файла на диске ещё нет - в кэш попадают только данные из памяти
+	if err := poller.Poll(); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+
+	data, ok := c.Get(cache.Key("main", filepath.Join(dataDir, "20240115.db.gz")))
+	if !ok {
+		t.Fatal("Today's entry not found in cache")
+	}
+	if len(data.Data) != 4 {
+		t.Errorf("Expected 4 rows, got %d", len(data.Data))
+	}
+}
+
+// writeScaled пишет базу из строк data, пересчитав счётчики через scale
+func writeScaled(t *testing.T, path string, data *converter.TrafficData, scale func(uint64) uint64) {
+	t.Helper()
+
+	scaled := &converter.TrafficData{Columns: data.Columns}
+	for _, row := range data.Data {
+		copied := append([]interface{}(nil), row...)
+		for _, col := range counterColumns {
+			copied[col] = scale(copied[col].(uint64))
+		}
+		scaled.Data = append(scaled.Data, copied)
+	}
+
+	records, err := converter.RecordsFromTrafficData(scaled)
+	if err != nil {
+		t.Fatalf("RecordsFromTrafficData failed: %v", err)
+	}
+	if err := converter.New().WriteFile(path, converter.Database{}, records); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+func TestPoller_ScannerReloadKeepsLiveCounters(t *testing.T) {
+	dataDir := t.TempDir()
+	c := cache.New()
+	liveData := loadJSONFixture(t)
+
+	poller := NewPoller(&staticSource{data: liveData}, c, "main", func() string { return dataDir }, time.Second)
+	poller.now = func() time.Time { return time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local) }
+	path := poller.TodayPath()
+	key := cache.Key("main", path)
+
+	// На диске более старые счётчики, чем в памяти nlbwmon
+	writeScaled(t, path, liveData, func(v uint64) uint64 { return v / 2 })
+	if err := poller.Poll(); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+
+	sets := 0
+	c.OnSet(func(string, *converter.TrafficData, bool) { sets++ })
+
+	// Сканер перечитал тот же файл - счётчики не откатываются, событий нет
+	if err := c.LoadFile("main", path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	data, _ := c.Get(key)
+	if row := findRow(data, "TCP", 443, "aa:bb:cc:dd:ee:01"); row[5] != uint64(150) || row[6] != uint64(3000000) {
+		t.Errorf("Live counters must survive a reload, got conns=%v rx=%v", row[5], row[6])
+	}
+	if sets != 0 {
+		t.Errorf("Reload without new data must not update the cache, got %d updates", sets)
+	}
+
+	// nlbwmon сбросил на диск больше, чем видел последний опрос
+	writeScaled(t, path, liveData, func(v uint64) uint64 { return v * 2 })
+	if err := c.LoadFile("main", path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	data, _ = c.Get(key)
+	if row := findRow(data, "TCP", 443, "aa:bb:cc:dd:ee:01"); row[6] != uint64(6000000) {
+		t.Errorf("Expected newer disk counters, got rx=%v", row[6])
+	}
+	if len(data.Data) != len(liveData.Data) || sets != 1 {
+		t.Errorf("Expected one update with %d rows, got %d updates and %d rows", len(liveData.Data), sets, len(data.Data))
+	}
+
+	// Остальные файлы загружаются как есть
+	other := filepath.Join(dataDir, "20240114.db.gz")
+	writeScaled(t, other, liveData, func(v uint64) uint64 { return v / 2 })
+	c.Set(cache.Key("main", other), liveData)
+	if err := c.LoadFile("main", other); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	data, _ = c.Get(cache.Key("main", other))
+	if row := findRow(data, "TCP", 443, "aa:bb:cc:dd:ee:01"); row[6] != uint64(1500000) {
+		t.Errorf("Past days must not be merged, got rx=%v", row[6])
+	}
+}
+
+func TestPoller_TodayPathFollowsExistingFormat(t *testing.T) {
+	dataDir := t.TempDir()
+	c := cache.New()
+
+	poller := NewPoller(&staticSource{data: loadJSONFixture(t)}, c, "main", func() string { return dataDir }, time.Second)
+	poller.now = func() time.Time { return time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local) }
+
+	if got := filepath.Base(poller.TodayPath()); got != "20240115.db.gz" {
+		t.Errorf("Expected .db.gz without a file on disk, got %s", got)
+	}
+
+	// В data_dir лежит выгрузка `nlbw -c json` - живые данные идут под её ключ
+	fixture, err := os.ReadFile("testdata/nlbw.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	jsonPath := filepath.Join(dataDir, "20240115.json")
+	if err := os.WriteFile(jsonPath, fixture, 0644); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+	if err := poller.Poll(); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+
+	if _, ok := c.Get(cache.Key("main", jsonPath)); !ok {
+		t.Error("Live data must be stored under the existing export's key")
+	}
+	if len(c.GetAll()) != 1 {
+		t.Errorf("Expected a single cache entry for today, got %d", len(c.GetAll()))
+	}
+}
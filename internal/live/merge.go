@@ -0,0 +1,86 @@
+package live
+
+import (
+	"fmt"
+
+	"nlbw-ui/internal/converter"
+)
+
+// counterColumns - индексы счётчиков в строке TrafficData (conns..tx_pkts)
+var counterColumns = []int{5, 6, 7, 8, 9}
+
+// Merge объединяет данные с диска и данные из памяти nlbwmon.
+// Счётчики в памяти только растут в пределах периода, поэтому для каждой
+// записи (family, proto, port, mac, ip) берётся максимум из двух значений.
+// Записи, которых нет на диске, добавляются целиком.
+func Merge(disk, live *converter.TrafficData) *converter.TrafficData {
+	merged, _ := merge(disk, live)
+	return merged
+}
+
+// merge - Merge, дополнительно сообщающий, есть ли на диске что-то новее live:
+// запись, которой нет в live, или счётчик больше
+func merge(disk, live *converter.TrafficData) (*converter.TrafficData, bool) {
+	merged := &converter.TrafficData{
+		Columns: converter.Columns,
+	}
+
+	var diskRows [][]interface{}
+	if disk != nil {
+		diskRows = disk.Data
+	}
+	var liveRows [][]interface{}
+	if live != nil {
+		liveRows = live.Data
+	}
+
+	merged.Data = make([][]interface{}, 0, len(diskRows)+len(liveRows))
+	positions := make(map[string]int, len(diskRows))
+	diskAhead := false
+
+	for _, row := range diskRows {
+		if len(row) < 11 {
+			continue
+		}
+		copied := make([]interface{}, len(row))
+		copy(copied, row)
+		positions[rowKey(row)] = len(merged.Data)
+		merged.Data = append(merged.Data, copied)
+	}
+
+	matched := make(map[int]bool, len(positions))
+	for _, row := range liveRows {
+		if len(row) < 11 {
+			continue
+		}
+
+		pos, ok := positions[rowKey(row)]
+		if !ok {
+			copied := make([]interface{}, len(row))
+			copy(copied, row)
+			merged.Data = append(merged.Data, copied)
+			continue
+		}
+		matched[pos] = true
+
+		target := merged.Data[pos]
+		for _, col := range counterColumns {
+			liveValue, _ := row[col].(uint64)
+			diskValue, _ := target[col].(uint64)
+			if liveValue > diskValue {
+				target[col] = liveValue
+			} else if diskValue > liveValue {
+				diskAhead = true
+			}
+		}
+	}
+
+	if len(matched) < len(positions) {
+		diskAhead = true
+	}
+	return merged, diskAhead
+}
+
+func rowKey(row []interface{}) string {
+	return fmt.Sprintf("%v|%v|%v|%v|%v", row[0], row[1], row[2], row[3], row[4])
+}
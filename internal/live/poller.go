@@ -0,0 +1,113 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/converter"
+)
+
+// Poller периодически опрашивает Source и подмешивает счётчики
+// из памяти nlbwmon в сегодняшний файл в кэше
+type Poller struct {
+	source     Source
+	cache      *cache.Cache
+	sourceName string
+	converter  *converter.Converter
+	dataDir    func() string
+	interval   time.Duration
+	now        func() time.Time
+}
+
+// NewPoller создаёт поллер для источника sourceName. dataDir вызывается на каждом опросе,
+// чтобы смена data_dir при перезагрузке конфига подхватывалась сразу.
+// Поллер регистрирует в кэше слияние, чтобы перечитывание сегодняшнего файла
+// сканером не откатывало живые счётчики назад.
+func NewPoller(source Source, c *cache.Cache, sourceName string, dataDir func() string, interval time.Duration) *Poller {
+	p := &Poller{
+		source:     source,
+		cache:      c,
+		sourceName: sourceName,
+		converter:  converter.New(),
+		dataDir:    dataDir,
+		interval:   interval,
+		now:        time.Now,
+	}
+	c.SetLoadMerger(p.mergeLoaded)
+	return p
+}
+
+// mergeLoaded объединяет сегодняшний файл, перечитанный с диска, с уже слитыми
+// данными в кэше. Если на диске нет ничего новее, кэш не меняется - без лишних
+// событий file_modified и day_updated.
+func (p *Poller) mergeLoaded(key string, cached, loaded *converter.TrafficData) (*converter.TrafficData, bool) {
+	if cached == nil || key != cache.Key(p.sourceName, p.TodayPath()) {
+		return nil, true
+	}
+	return merge(loaded, cached)
+}
+
+// Run опрашивает источник до закрытия stop
+func (p *Poller) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Poll(); err != nil {
+			fmt.Printf("Live poll error: %v\n", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll выполняет один опрос и обновляет сегодняшнюю запись в кэше
+func (p *Poller) Poll() error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+	defer cancel()
+
+	liveData, err := p.source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := p.TodayPath()
+
+	// Данные с диска читаем из файла, а не из кэша: в кэше уже может
+	// лежать результат предыдущего слияния
+	var diskData *converter.TrafficData
+	if _, err := os.Stat(path); err == nil {
+		// Файл может быть обрезан, пока nlbwmon его перезаписывает -
+		// прочитанной части достаточно, недостающее перекроют живые счётчики
+		var truncated *converter.TruncatedError
+		diskData, err = p.converter.ConvertFile(path)
+		if err != nil && !errors.As(err, &truncated) {
+			return fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+		}
+	}
+
+	p.cache.Set(cache.Key(p.sourceName, path), Merge(diskData, liveData))
+	return nil
+}
+
+// TodayPath возвращает путь сегодняшнего файла: существующий файл за сегодня
+// в самом приоритетном формате (.db.gz, .db, .json, .csv), иначе YYYYMMDD.db.gz.
+// Так живые счётчики попадают под тот же ключ кэша, что и файл со сканера.
+func (p *Poller) TodayPath() string {
+	base := filepath.Join(p.dataDir(), p.now().Format("20060102"))
+	for _, ext := range converter.Extensions {
+		if info, err := os.Stat(base + ext); err == nil && info.Mode().IsRegular() {
+			return base + ext
+		}
+	}
+	return base + ".db.gz"
+}
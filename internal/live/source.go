@@ -0,0 +1,89 @@
+package live
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"nlbw-ui/internal/config"
+	"nlbw-ui/internal/converter"
+)
+
+// Source возвращает текущие (ещё не записанные на диск) счётчики nlbwmon
+type Source interface {
+	Fetch(ctx context.Context) (*converter.TrafficData, error)
+}
+
+// NewSource создаёт источник по настройкам live из конфига
+func NewSource(cfg config.LiveConfig) Source {
+	if cfg.Command != "" {
+		return &CommandSource{Command: cfg.Command}
+	}
+	return &SocketSource{Path: cfg.Socket}
+}
+
+// SocketSource читает базу текущего периода через управляющий сокет nlbwmon.
+// На команду "dump" nlbwmon отвечает заголовком базы и записями
+// в том же формате, что и в файле, но без сжатия.
+type SocketSource struct {
+	Path    string
+	Timeout time.Duration
+}
+
+func (s *SocketSource) Fetch(ctx context.Context) (*converter.TrafficData, error) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "unix", s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", s.Path, err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("dump")); err != nil {
+		return nil, fmt.Errorf("failed to send dump command: %w", err)
+	}
+
+	data, err := converter.New().ConvertReader(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dump: %w", err)
+	}
+
+	return data, nil
+}
+
+// CommandSource запускает внешнюю команду, печатающую вывод `nlbw -c json`
+type CommandSource struct {
+	Command string
+}
+
+func (s *CommandSource) Fetch(ctx context.Context) (*converter.TrafficData, error) {
+	args := strings.Fields(s.Command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", s.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return converter.ParseJSON(&stdout)
+}
@@ -0,0 +1,282 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nlbw-ui/internal/converter"
+)
+
+// fileState - состояние одного удалённого файла
+type fileState struct {
+	ETag         string
+	LastModified string
+	Frozen       bool // true = файл больше не будет меняться, не запрашиваем
+}
+
+// Fetcher зеркалирует базы nlbwmon с удалённого HTTP сервера в локальную
+// директорию, откуда их подхватывает обычный scanner.
+//
+// Сервер должен отдавать по baseURL список файлов ({"files": [...]},
+// массив имён или массив объектов с полем name, как nginx autoindex_format json),
+// а сами файлы - по baseURL/<имя>.
+type Fetcher struct {
+	baseURL string
+	dir     string
+	client  *http.Client
+
+	mu    sync.Mutex
+	files map[string]*fileState
+}
+
+// New создаёт fetcher, сохраняющий файлы в dir
+func New(baseURL, dir string) *Fetcher {
+	return &Fetcher{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		dir:     dir,
+		client:  &http.Client{Timeout: 5 * time.Minute},
+		files:   make(map[string]*fileState),
+	}
+}
+
+// Run синхронизирует файлы сразу и затем с заданным интервалом до закрытия stop
+func (f *Fetcher) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		if _, err := f.Sync(ctx); err != nil {
+			fmt.Printf("Remote sync error (%s): %v\n", f.baseURL, err)
+		}
+		cancel()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync скачивает новые и изменившиеся файлы.
+// Как и scanner, при первой синхронизации загружает всё, а затем
+// перепроверяет только последние 2 файла (сегодня + вчера);
+// более старые считаются замороженными и не запрашиваются повторно.
+func (f *Fetcher) Sync(ctx context.Context) ([]string, error) {
+	names, err := f.listRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+
+	// Сортируем по имени (YYYYMMDD.db.gz) - новые в конце
+	sort.Strings(names)
+
+	var updated []string
+	for _, name := range names {
+		f.mu.Lock()
+		state, exists := f.files[name]
+		if !exists {
+			state = &fileState{}
+			f.files[name] = state
+		}
+		frozen := state.Frozen
+		f.mu.Unlock()
+
+		if frozen {
+			continue
+		}
+
+		changed, err := f.fetch(ctx, name, state)
+		if err != nil {
+			fmt.Printf("Warning: failed to fetch %s: %v\n", name, err)
+			continue
+		}
+		if changed {
+			updated = append(updated, name)
+		}
+	}
+
+	// Замораживаем всё, кроме последних 2 файлов.
+	// Файл без локальной копии (ошибка загрузки) попробуем скачать снова.
+	if len(names) > 2 {
+		f.mu.Lock()
+		for _, name := range names[:len(names)-2] {
+			if f.exists(name) {
+				f.files[name].Frozen = true
+			}
+		}
+		f.mu.Unlock()
+	}
+
+	return updated, nil
+}
+
+// listRemote получает список файлов с сервера
+func (f *Fetcher) listRemote(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.baseURL+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote files: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list remote files: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file list: %w", err)
+	}
+
+	candidates, err := parseIndex(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Берём только файлы поддерживаемых форматов с безопасным именем
+	names := make([]string, 0, len(candidates))
+	for _, name := range candidates {
+		if name != path.Base(name) || strings.ContainsAny(name, `/\`) {
+			continue
+		}
+		if _, ok := converter.TrimExtension(name); ok {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// parseIndex понимает несколько форматов списка файлов
+func parseIndex(body []byte) ([]string, error) {
+	var wrapped struct {
+		Files []string `json:"files"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err == nil && wrapped.Files != nil {
+		return wrapped.Files, nil
+	}
+
+	var plain []string
+	if err := json.Unmarshal(body, &plain); err == nil {
+		return plain, nil
+	}
+
+	var entries []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(body, &entries); err == nil {
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if entry.Type == "" || entry.Type == "file" {
+				names = append(names, entry.Name)
+			}
+		}
+		return names, nil
+	}
+
+	return nil, fmt.Errorf("unsupported file list format")
+}
+
+// fetch скачивает файл, если он изменился с прошлого раза (ETag/If-Modified-Since).
+// Файл записывается атомарно через временный файл и rename.
+func (f *Fetcher) fetch(ctx context.Context, name string, state *fileState) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.baseURL+"/"+name, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// Условные заголовки имеют смысл, только если локальная копия на месте.
+	// После перезапуска ETag неизвестен - используем mtime копии,
+	// которой при скачивании присваивается Last-Modified сервера.
+	f.mu.Lock()
+	if info, err := os.Stat(filepath.Join(f.dir, name)); err == nil {
+		if state.ETag != "" {
+			req.Header.Set("If-None-Match", state.ETag)
+		}
+		if state.LastModified != "" {
+			req.Header.Set("If-Modified-Since", state.LastModified)
+		} else {
+			req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+		}
+	}
+	f.mu.Unlock()
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(f.dir, "."+name+".*.tmp")
+	if err != nil {
+		return false, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return false, fmt.Errorf("failed to download: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		os.Chtimes(tmp.Name(), modTime, modTime)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(f.dir, name)); err != nil {
+		return false, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	f.mu.Lock()
+	state.ETag = resp.Header.Get("ETag")
+	state.LastModified = resp.Header.Get("Last-Modified")
+	f.mu.Unlock()
+
+	fmt.Printf("Fetched %s from %s\n", name, f.baseURL)
+	return true, nil
+}
+
+func (f *Fetcher) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(f.dir, name))
+	return err == nil
+}
@@ -0,0 +1,185 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRemote - HTTP сервер с файлами и счётчиком запросов к каждому из них
+type fakeRemote struct {
+	mu       sync.Mutex
+	files    map[string]string
+	modTimes map[string]time.Time
+	requests map[string]int
+}
+
+func newFakeRemote(files map[string]string) *fakeRemote {
+	r := &fakeRemote{
+		files:    files,
+		modTimes: make(map[string]time.Time),
+		requests: make(map[string]int),
+	}
+	for name := range files {
+		r.modTimes[name] = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return r
+}
+
+func (r *fakeRemote) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := strings.TrimPrefix(req.URL.Path, "/raw/")
+	if name == "" {
+		names := make([]string, 0, len(r.files))
+		for n := range r.files {
+			names = append(names, n)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"files": names})
+		return
+	}
+
+	content, ok := r.files[name]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	r.requests[name]++
+
+	etag := `"` + content + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", r.modTimes[name].Format(http.TimeFormat))
+	if req.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write([]byte(content))
+}
+
+func (r *fakeRemote) update(name, content string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[name] = content
+	r.modTimes[name] = r.modTimes[name].Add(time.Hour)
+}
+
+func (r *fakeRemote) count(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[name]
+}
+
+func TestFetcher_Sync(t *testing.T) {
+	remote := newFakeRemote(map[string]string{
+		"20240101.db.gz": "day1",
+		"20240102.db.gz": "day2",
+		"20240103.db.gz": "day3",
+		"notes.txt":      "ignored",
+	})
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	dir := t.TempDir()
+	fetcher := New(server.URL+"/raw/", dir)
+	ctx := context.Background()
+
+	updated, err := fetcher.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(updated) != 3 {
+		t.Fatalf("Expected 3 downloaded files, got %v", updated)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "20240103.db.gz"))
+	if err != nil || string(content) != "day3" {
+		t.Fatalf("Unexpected mirrored content: %q, %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); !os.IsNotExist(err) {
+		t.Error("Unsupported file must not be mirrored")
+	}
+
+	// Повторная синхронизация: старый файл заморожен, свежие отвечают 304
+	updated, err = fetcher.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Second sync failed: %v", err)
+	}
+	if len(updated) != 0 {
+		t.Errorf("Expected no updates, got %v", updated)
+	}
+	if remote.count("20240101.db.gz") != 1 {
+		t.Errorf("Frozen file was requested again: %d requests", remote.count("20240101.db.gz"))
+	}
+	if remote.count("20240103.db.gz") != 2 {
+		t.Errorf("Recent file must be revalidated: %d requests", remote.count("20240103.db.gz"))
+	}
+
+	// Изменение свежего файла скачивается, изменение замороженного - нет
+	remote.update("20240103.db.gz", "day3-updated")
+	remote.update("20240101.db.gz", "day1-updated")
+
+	updated, err = fetcher.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Third sync failed: %v", err)
+	}
+	if len(updated) != 1 || updated[0] != "20240103.db.gz" {
+		t.Errorf("Expected only today's file to update, got %v", updated)
+	}
+
+	content, _ = os.ReadFile(filepath.Join(dir, "20240103.db.gz"))
+	if string(content) != "day3-updated" {
+		t.Errorf("Mirrored file was not updated: %q", content)
+	}
+}
+
+func TestFetcher_IfModifiedSinceAfterRestart(t *testing.T) {
+	var conditional int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/" {
+			w.Write([]byte(`["20240101.db.gz"]`))
+			return
+		}
+		modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		if since, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && !modTime.After(since) {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if _, err := New(server.URL, dir).Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Новый экземпляр не знает ETag, но должен использовать mtime копии
+	updated, err := New(server.URL, dir).Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync after restart failed: %v", err)
+	}
+	if len(updated) != 0 || conditional != 1 {
+		t.Errorf("Expected 304 after restart, updated=%v conditional=%d", updated, conditional)
+	}
+}
+
+func TestFetcher_ListError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if _, err := New(server.URL, t.TempDir()).Sync(context.Background()); err == nil {
+		t.Error("Expected error for failing index")
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"nlbw-ui/internal/converter"
 )
 
 type FileInfo struct {
@@ -25,8 +28,11 @@ type Scanner struct {
 	dataDir    string
 	files      map[string]*fileState
 	mu         sync.RWMutex
+	scanMu     sync.Mutex // не даёт сменить директорию посреди Scan
 	onNewFile  func(path string)
 	onModified func(path string)
+	onFrozen   func(path string)
+	onDeleted  func(path string)
 }
 
 func New(dataDir string) *Scanner {
@@ -44,40 +50,75 @@ func (s *Scanner) OnModified(fn func(path string)) {
 	s.onModified = fn
 }
 
-// Scan проверяет файлы на изменения
-// При первом запуске сканирует все файлы
-// При последующих - только последние 2 (сегодня + вчера)
+// OnFrozen вызывается, когда файл выпадает из последних 2 и больше не проверяется
+func (s *Scanner) OnFrozen(fn func(path string)) {
+	s.onFrozen = fn
+}
+
+// OnDeleted вызывается для файла, который пропал из директории (удалён или переименован)
+func (s *Scanner) OnDeleted(fn func(path string)) {
+	s.onDeleted = fn
+}
+
+// SetDataDir переключает сканер на другую директорию.
+// Состояние файлов сбрасывается, следующий Scan будет первым.
+func (s *Scanner) SetDataDir(dataDir string) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dataDir = dataDir
+	s.files = make(map[string]*fileState)
+}
+
+// DataDir возвращает текущую директорию сканирования
+func (s *Scanner) DataDir() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dataDir
+}
+
+// Scan проверяет файлы на изменения.
+// Список директории читается каждый раз: новые имена (в том числе старые
+// даты, восстановленные из бэкапа) и удалённые файлы замечаются сразу.
+// Замороженные файлы (все, кроме последних 2) не проверяются.
 func (s *Scanner) Scan() ([]FileInfo, error) {
-	pattern := filepath.Join(s.dataDir, "*.db.gz")
-	matches, err := filepath.Glob(pattern)
+	return s.scan(false)
+}
+
+// Rescan - полный проход, который стоит делать реже Scan: дополнительно
+// проверяет замороженные файлы и размораживает те, что неожиданно изменились
+func (s *Scanner) Rescan() ([]FileInfo, error) {
+	return s.scan(true)
+}
+
+func (s *Scanner) scan(full bool) ([]FileInfo, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	matches, err := s.listFiles()
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan directory: %w", err)
+		return nil, err
 	}
 
 	// Сортируем по имени (YYYYMMDD.db.gz) - новые в конце
 	sort.Strings(matches)
 
-	s.mu.RLock()
-	isFirstScan := len(s.files) == 0
-	s.mu.RUnlock()
-
-	var filesToCheck []string
-
-	if isFirstScan {
-		// Первый запуск - проверяем все файлы
-		filesToCheck = matches
-	} else {
-		// Последующие запуски - только последние 2 файла
-		if len(matches) >= 2 {
-			filesToCheck = matches[len(matches)-2:]
-		} else {
-			filesToCheck = matches
-		}
-	}
+	s.removeMissing(matches)
 
 	var changes []FileInfo
 
-	for _, path := range filesToCheck {
+	for _, path := range matches {
+		s.mu.RLock()
+		state, exists := s.files[path]
+		s.mu.RUnlock()
+
+		// Замороженные файлы не трогаем, кроме полного прохода
+		if exists && state.Frozen && !full {
+			continue
+		}
+
 		info, err := os.Stat(path)
 		if err != nil {
 			fmt.Printf("Warning: failed to stat %s: %v\n", path, err)
@@ -87,10 +128,6 @@ func (s *Scanner) Scan() ([]FileInfo, error) {
 		size := info.Size()
 		modTime := info.ModTime()
 
-		s.mu.RLock()
-		state, exists := s.files[path]
-		s.mu.RUnlock()
-
 		if !exists {
 			// Новый файл
 			s.mu.Lock()
@@ -106,8 +143,12 @@ func (s *Scanner) Scan() ([]FileInfo, error) {
 			if s.onNewFile != nil {
 				s.onNewFile(path)
 			}
-		} else if !state.Frozen && (state.Size != size || !state.ModTime.Equal(modTime)) {
-			// Файл изменился
+		} else if state.Size != size || !state.ModTime.Equal(modTime) {
+			// Файл изменился. Замороженный файл меняться не должен -
+			// размораживаем, и при необходимости он заморозится снова ниже
+			if state.Frozen {
+				fmt.Printf("Frozen file changed: %s\n", path)
+			}
 			s.mu.Lock()
 			s.files[path] = &fileState{
 				Size:    size,
@@ -124,20 +165,93 @@ func (s *Scanner) Scan() ([]FileInfo, error) {
 		}
 	}
 
-	// После первого скана замораживаем все файлы кроме последних 2
-	if isFirstScan && len(matches) > 2 {
+	// Замораживаем все файлы кроме последних 2: после первого скана -
+	// всю историю, дальше - вчерашний файл при смене дня, а также
+	// добавленные задним числом и изменившиеся старые файлы
+	var frozen []string
+	if len(matches) > 2 {
 		s.mu.Lock()
 		for i := 0; i < len(matches)-2; i++ {
-			if state, ok := s.files[matches[i]]; ok {
+			if state, ok := s.files[matches[i]]; ok && !state.Frozen {
 				state.Frozen = true
+				frozen = append(frozen, matches[i])
 			}
 		}
 		s.mu.Unlock()
 	}
 
+	if s.onFrozen != nil {
+		for _, path := range frozen {
+			s.onFrozen(path)
+		}
+	}
+
 	return changes, nil
 }
 
+// removeMissing забывает файлы, которых больше нет в директории.
+// Переименование выглядит как удаление старого имени и новый файл.
+func (s *Scanner) removeMissing(matches []string) {
+	present := make(map[string]bool, len(matches))
+	for _, path := range matches {
+		present[path] = true
+	}
+
+	var deleted []string
+	s.mu.Lock()
+	for path := range s.files {
+		if !present[path] {
+			delete(s.files, path)
+			deleted = append(deleted, path)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Strings(deleted)
+	if s.onDeleted != nil {
+		for _, path := range deleted {
+			s.onDeleted(path)
+		}
+	}
+}
+
+// listFiles возвращает файлы всех поддерживаемых форматов с именем вида YYYYMMDD.<ext>.
+// Если за одну дату есть несколько форматов, берётся первый по порядку
+// converter.Extensions, чтобы день не учитывался дважды.
+func (s *Scanner) listFiles() ([]string, error) {
+	dataDir := s.DataDir()
+
+	var matches []string
+	seenDates := make(map[string]bool)
+
+	for _, ext := range converter.Extensions {
+		found, err := filepath.Glob(filepath.Join(dataDir, "*"+ext))
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+
+		for _, path := range found {
+			name := strings.TrimSuffix(filepath.Base(path), ext)
+			if !isDateName(name) || seenDates[name] {
+				continue
+			}
+			seenDates[name] = true
+			matches = append(matches, path)
+		}
+	}
+
+	return matches, nil
+}
+
+// isDateName проверяет, что имя файла без расширения - дата YYYYMMDD
+func isDateName(name string) bool {
+	if len(name) != 8 {
+		return false
+	}
+	_, err := time.Parse("20060102", name)
+	return err == nil
+}
+
 func (s *Scanner) GetFiles() map[string]FileInfo {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
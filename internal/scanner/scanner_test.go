@@ -0,0 +1,173 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+// recorder собирает вызовы колбэков сканера
+type recorder struct {
+	added, modified, deleted, frozen []string
+}
+
+func newRecordedScanner(dir string) (*Scanner, *recorder) {
+	s := New(dir)
+	r := &recorder{}
+	s.OnNewFile(func(path string) { r.added = append(r.added, filepath.Base(path)) })
+	s.OnModified(func(path string) { r.modified = append(r.modified, filepath.Base(path)) })
+	s.OnDeleted(func(path string) { r.deleted = append(r.deleted, filepath.Base(path)) })
+	s.OnFrozen(func(path string) { r.frozen = append(r.frozen, filepath.Base(path)) })
+	return s, r
+}
+
+func TestScanner_ScanDetectsDeletedAndBackfilled(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"20240102.db.gz", "20240103.db.gz", "20240104.db.gz"} {
+		writeFile(t, dir, name, "data")
+	}
+
+	s, r := newRecordedScanner(dir)
+	if _, err := s.Scan(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(r.added) != 3 || len(r.frozen) != 1 || r.frozen[0] != "20240102.db.gz" {
+		t.Fatalf("Unexpected first scan: %+v", r)
+	}
+
+	// Удаляем старый файл и добавляем ещё более старый задним числом
+	os.Remove(filepath.Join(dir, "20240102.db.gz"))
+	writeFile(t, dir, "20240101.db.gz", "backup")
+	r.added = nil
+
+	// Обычного Scan достаточно: список директории читается каждый раз
+	if _, err := s.Scan(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if len(r.deleted) != 1 || r.deleted[0] != "20240102.db.gz" {
+		t.Errorf("Expected deleted 20240102.db.gz, got %v", r.deleted)
+	}
+	if len(r.added) != 1 || r.added[0] != "20240101.db.gz" {
+		t.Errorf("Expected backfilled 20240101.db.gz, got %v", r.added)
+	}
+	if _, ok := s.GetFiles()[filepath.Join(dir, "20240102.db.gz")]; ok {
+		t.Error("Deleted file must be forgotten")
+	}
+	if len(r.frozen) != 2 || r.frozen[1] != "20240101.db.gz" {
+		t.Errorf("Backfilled file must be frozen, got %v", r.frozen)
+	}
+}
+
+func TestScanner_RescanUnfreezesChangedFile(t *testing.T) {
+	dir := t.TempDir()
+	old := writeFile(t, dir, "20240101.db.gz", "data")
+	writeFile(t, dir, "20240102.db.gz", "data")
+	writeFile(t, dir, "20240103.db.gz", "data")
+
+	s, r := newRecordedScanner(dir)
+	if _, err := s.Scan(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	writeFile(t, dir, "20240101.db.gz", "rewritten")
+
+	// Обычный Scan замороженный файл не проверяет
+	if _, err := s.Scan(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(r.modified) != 0 {
+		t.Fatalf("Frozen file must not be checked by Scan, got %v", r.modified)
+	}
+
+	changes, err := s.Rescan()
+	if err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path != old || len(r.modified) != 1 {
+		t.Fatalf("Expected changed frozen file, got %v", changes)
+	}
+
+	// После перезагрузки файл снова заморожен
+	if len(r.frozen) != 2 {
+		t.Errorf("Expected file to be frozen again, got %v", r.frozen)
+	}
+}
+
+func TestScanner_ExtensionPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"20240101.csv", "20240101.json", "20240101.db",
+		"20240102.json", "20240102.csv",
+		"20240103.csv",
+		"20240104.txt", "2024010.db.gz", "20241301.db.gz", "notes.json",
+	} {
+		writeFile(t, dir, name, "data")
+	}
+
+	s, r := newRecordedScanner(dir)
+	if _, err := s.Scan(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	// За дату берётся один файл в порядке converter.Extensions
+	expected := []string{"20240101.db", "20240102.json", "20240103.csv"}
+	if !equalSorted(r.added, expected) {
+		t.Fatalf("Expected %v, got %v", expected, r.added)
+	}
+
+	// Появился более приоритетный формат - прежний файл уходит как удалённый
+	writeFile(t, dir, "20240102.db.gz", "data")
+	r.added = nil
+	if _, err := s.Scan(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(r.added) != 1 || r.added[0] != "20240102.db.gz" {
+		t.Errorf("Expected 20240102.db.gz added, got %v", r.added)
+	}
+	if len(r.deleted) != 1 || r.deleted[0] != "20240102.json" {
+		t.Errorf("Expected 20240102.json deleted, got %v", r.deleted)
+	}
+}
+
+func TestIsDateName(t *testing.T) {
+	cases := map[string]bool{
+		"20240101":  true,
+		"20240229":  true,
+		"20230229":  false,
+		"20241301":  false,
+		"2024010":   false,
+		"202401011": false,
+		"2024-01-1": false,
+		"abcdefgh":  false,
+	}
+	for name, expected := range cases {
+		if isDateName(name) != expected {
+			t.Errorf("isDateName(%q) = %v, expected %v", name, !expected, expected)
+		}
+	}
+}
+
+func equalSorted(got, expected []string) bool {
+	if len(got) != len(expected) {
+		return false
+	}
+	sorted := append([]string(nil), got...)
+	sort.Strings(sorted)
+	for i := range sorted {
+		if sorted[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
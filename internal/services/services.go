@@ -0,0 +1,189 @@
+// Package services сопоставляет протокол и порт с известным сервисом
+// (443 -> HTTPS, 53 -> DNS, ...) и категорией приложения (web, vpn, ...).
+// Таблица встроена в бинарник и дополняется правилами из конфига.
+package services
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Категории приложений
+const (
+	CategoryWeb       = "web"
+	CategoryStreaming = "streaming"
+	CategoryGaming    = "gaming"
+	CategoryVPN       = "vpn"
+	CategoryDNS       = "dns"
+	CategoryMail      = "mail"
+	CategoryP2P       = "p2p"
+	CategoryVoIP      = "voip"
+	CategoryRemote    = "remote"
+	CategoryFile      = "file"
+	CategorySystem    = "system"
+	CategoryOther     = "other" // ничего не подошло
+)
+
+//go:embed services.txt
+var table string
+
+// Rule сопоставляет протокол и диапазон портов с категорией и сервисом.
+// icmp относится и к ICMP, и к IPV6-ICMP; any - к tcp, udp и icmp.
+// Прочие протоколы (GRE, ESP, ...) правилами не описываются.
+type Rule struct {
+	Proto    string // tcp, udp, icmp или any
+	From, To uint16 // диапазон портов включительно
+	Category string
+	Name     string // название сервиса, может быть пустым
+}
+
+// Match проверяет, подходит ли правило под протокол и порт
+func (r Rule) Match(proto string, port uint16) bool {
+	if port < r.From || port > r.To {
+		return false
+	}
+	proto = strings.ToLower(proto)
+	if proto == "ipv6-icmp" {
+		proto = "icmp"
+	}
+	return r.Proto == proto || (r.Proto == "any" && (proto == "tcp" || proto == "udp" || proto == "icmp"))
+}
+
+// builtin - встроенная таблица, byName - категория по названию сервиса
+var builtin, byName = mustParse(table)
+
+// Name возвращает название сервиса для протокола и порта или пустую строку
+func Name(proto string, port uint16) string {
+	if rule, ok := lookup(builtin, proto, port); ok {
+		return rule.Name
+	}
+	return ""
+}
+
+// Category возвращает встроенную категорию протокола и порта
+func Category(proto string, port uint16) string {
+	if rule, ok := lookup(builtin, proto, port); ok {
+		return rule.Category
+	}
+	return CategoryOther
+}
+
+// lookup возвращает первое подходящее правило. Правило для конкретного
+// протокола важнее правила any, поэтому оно ищется первым.
+func lookup(rules []Rule, proto string, port uint16) (Rule, bool) {
+	var fallback *Rule
+	for i, rule := range rules {
+		if !rule.Match(proto, port) {
+			continue
+		}
+		if rule.Proto != "any" {
+			return rule, true
+		}
+		if fallback == nil {
+			fallback = &rules[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return Rule{}, false
+}
+
+// Classifier определяет категорию строки трафика: сначала по правилам
+// пользователя (по порядку), затем по layer7 от nlbwmon, затем по таблице
+type Classifier struct {
+	rules []Rule
+}
+
+// NewClassifier создаёт классификатор с пользовательскими правилами
+func NewClassifier(rules []Rule) *Classifier {
+	return &Classifier{rules: rules}
+}
+
+// Classify возвращает категорию для протокола, порта и layer7 (может быть пустым)
+func (c *Classifier) Classify(proto string, port uint16, layer7 string) string {
+	for _, rule := range c.rules {
+		if rule.Match(proto, port) {
+			return rule.Category
+		}
+	}
+	if category, ok := byName[strings.ToLower(layer7)]; ok {
+		return category
+	}
+	return Category(proto, port)
+}
+
+// ParsePorts разбирает порт ("443") или диапазон ("6881-6889")
+func ParsePorts(value string) (uint16, uint16, error) {
+	fromStr, toStr, isRange := strings.Cut(strings.TrimSpace(value), "-")
+	from, err := strconv.ParseUint(strings.TrimSpace(fromStr), 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port %q", value)
+	}
+	if !isRange {
+		return uint16(from), uint16(from), nil
+	}
+
+	to, err := strconv.ParseUint(strings.TrimSpace(toStr), 10, 16)
+	if err != nil || to < from {
+		return 0, 0, fmt.Errorf("invalid port range %q", value)
+	}
+	return uint16(from), uint16(to), nil
+}
+
+// ValidProto проверяет протокол правила
+func ValidProto(proto string) bool {
+	switch proto {
+	case "tcp", "udp", "icmp", "any":
+		return true
+	}
+	return false
+}
+
+// parse разбирает таблицу: строки "proto ports category name", # - комментарий
+func parse(data string) ([]Rule, map[string]string, error) {
+	var rules []Rule
+	names := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 4 {
+			return nil, nil, fmt.Errorf("line %d: expected proto, port, category and name", line)
+		}
+
+		rule := Rule{
+			Proto:    strings.ToLower(fields[0]),
+			Category: fields[2],
+			Name:     strings.Join(fields[3:], " "),
+		}
+		if !ValidProto(rule.Proto) {
+			return nil, nil, fmt.Errorf("line %d: invalid protocol %q", line, fields[0])
+		}
+		var err error
+		if rule.From, rule.To, err = ParsePorts(fields[1]); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		rules = append(rules, rule)
+		names[strings.ToLower(rule.Name)] = rule.Category
+	}
+
+	return rules, names, scanner.Err()
+}
+
+func mustParse(data string) ([]Rule, map[string]string) {
+	rules, names, err := parse(data)
+	if err != nil {
+		panic(fmt.Sprintf("invalid services table: %v", err))
+	}
+	return rules, names
+}
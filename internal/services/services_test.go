@@ -0,0 +1,77 @@
+package services
+
+import "testing"
+
+func TestName(t *testing.T) {
+	cases := []struct {
+		proto string
+		port  uint16
+		want  string
+	}{
+		{"TCP", 443, "HTTPS"},
+		{"UDP", 443, "QUIC"},
+		{"udp", 53, "DNS"},
+		{"TCP", 853, "DoT"},
+		{"UDP", 1194, "OpenVPN"},
+		{"ICMP", 0, "ICMP"},
+		{"IPV6-ICMP", 0, "ICMP"},
+		{"TCP", 6885, "BitTorrent"},
+		{"TCP", 12345, ""},
+		{"GRE", 53, ""},
+	}
+
+	for _, c := range cases {
+		if got := Name(c.proto, c.port); got != c.want {
+			t.Errorf("Name(%s, %d) = %q, want %q", c.proto, c.port, got, c.want)
+		}
+	}
+}
+
+func TestClassifier(t *testing.T) {
+	c := NewClassifier([]Rule{
+		{Proto: "udp", From: 443, To: 443, Category: CategoryStreaming},
+		{Proto: "any", From: 40000, To: 40100, Category: CategoryGaming},
+	})
+
+	cases := []struct {
+		proto  string
+		port   uint16
+		layer7 string
+		want   string
+	}{
+		{"UDP", 443, "", CategoryStreaming}, // правило пользователя важнее таблицы
+		{"TCP", 443, "", CategoryWeb},
+		{"TCP", 40050, "", CategoryGaming},
+		{"ICMP", 40050, "", CategoryGaming}, // any включает ICMP
+		{"IPV6-ICMP", 0, "", CategorySystem},
+		{"GRE", 40050, "", CategoryOther},
+		{"TCP", 12345, "BitTorrent", CategoryP2P}, // по layer7 от nlbwmon
+		{"TCP", 12345, "", CategoryOther},
+		{"UDP", 51820, "", CategoryVPN},
+	}
+
+	for _, tc := range cases {
+		if got := c.Classify(tc.proto, tc.port, tc.layer7); got != tc.want {
+			t.Errorf("Classify(%s, %d, %q) = %q, want %q", tc.proto, tc.port, tc.layer7, got, tc.want)
+		}
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	if from, to, err := ParsePorts("6881-6889"); err != nil || from != 6881 || to != 6889 {
+		t.Errorf("Unexpected range: %d-%d, %v", from, to, err)
+	}
+	for _, value := range []string{"", "http", "10-5", "70000", "1-70000"} {
+		if _, _, err := ParsePorts(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, data := range []string{"tcp 443 web", "tcp https web HTTPS", "tcp 70000 web Big", "sctp 1 web X"} {
+		if _, _, err := parse(data); err == nil {
+			t.Errorf("Expected error for %q", data)
+		}
+	}
+}
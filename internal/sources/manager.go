@@ -0,0 +1,292 @@
+package sources
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"nlbw-ui/internal/archive"
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/config"
+	"nlbw-ui/internal/converter"
+	"nlbw-ui/internal/remote"
+	"nlbw-ui/internal/scanner"
+)
+
+// remoteSync - запущенная синхронизация http источника
+type remoteSync struct {
+	config config.SourceConfig
+	stop   chan struct{}
+}
+
+// Manager держит по одному сканеру на каждый источник из конфига
+// и загружает найденные файлы в кэш с ключами, привязанными к источнику.
+// Для http источников дополнительно запускается remote.Fetcher, который
+// скачивает файлы в data_dir источника, а дальше работает обычный сканер.
+type Manager struct {
+	cache *cache.Cache
+
+	// mu сериализует сканирование и изменение набора источников,
+	// чтобы очистка кэша не пересекалась с загрузкой файлов
+	mu       sync.Mutex
+	scanners map[string]*scanner.Scanner
+	remotes  map[string]*remoteSync
+	order    []string
+
+	archiver *archive.Archiver
+	retain   map[string]bool // источники, у которых появились замороженные файлы
+}
+
+// NewManager создаёт пустой менеджер источников
+func NewManager(c *cache.Cache) *Manager {
+	return &Manager{
+		cache:    c,
+		scanners: make(map[string]*scanner.Scanner),
+		remotes:  make(map[string]*remoteSync),
+		retain:   make(map[string]bool),
+	}
+}
+
+// SetArchiver включает архивирование замороженных файлов.
+// Вызывается до первого Apply.
+func (m *Manager) SetArchiver(a *archive.Archiver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.archiver = a
+}
+
+// Apply приводит набор сканеров в соответствие с конфигом:
+// новые источники сканируются сразу, у изменившихся перестраивается кэш,
+// данные удалённых источников выбрасываются из кэша
+func (m *Manager) Apply(sources []config.SourceConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	configured := make(map[string]bool, len(sources))
+	order := make([]string, 0, len(sources))
+	var firstErr error
+
+	for _, src := range sources {
+		configured[src.Name] = true
+		order = append(order, src.Name)
+		m.applyRemote(src)
+
+		s, exists := m.scanners[src.Name]
+		switch {
+		case !exists:
+			s = m.newScanner(src.Name, src.DataDir)
+			m.scanners[src.Name] = s
+		case s.DataDir() != src.DataDir:
+			fmt.Printf("Source %s: data_dir changed: %s -> %s\n", src.Name, s.DataDir(), src.DataDir)
+			s.SetDataDir(src.DataDir)
+			m.cache.ClearSource(src.Name)
+		default:
+			continue
+		}
+
+		if _, err := s.Scan(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("source %s: %w", src.Name, err)
+		}
+		m.loadArchive(src.Name)
+	}
+
+	for name := range m.scanners {
+		if !configured[name] {
+			fmt.Printf("Source %s removed\n", name)
+			m.stopRemote(name)
+			delete(m.scanners, name)
+			m.cache.ClearSource(name)
+		}
+	}
+
+	m.order = order
+	return firstErr
+}
+
+// applyRemote запускает, перезапускает или останавливает синхронизацию http источника
+func (m *Manager) applyRemote(src config.SourceConfig) {
+	running, exists := m.remotes[src.Name]
+	if exists && running.config == src {
+		return
+	}
+
+	m.stopRemote(src.Name)
+	if src.Type != config.SourceHTTP {
+		return
+	}
+
+	fmt.Printf("Source %s: syncing from %s every %s\n", src.Name, src.URL, src.Interval)
+	rs := &remoteSync{config: src, stop: make(chan struct{})}
+	m.remotes[src.Name] = rs
+	go remote.New(src.URL, src.DataDir).Run(src.Interval, rs.stop)
+}
+
+func (m *Manager) stopRemote(name string) {
+	if running, ok := m.remotes[name]; ok {
+		close(running.stop)
+		delete(m.remotes, name)
+	}
+}
+
+// Scan проверяет изменения во всех источниках
+func (m *Manager) Scan() {
+	m.scan(false)
+}
+
+// Rescan делает полный проход по директориям всех источников
+func (m *Manager) Rescan() {
+	m.scan(true)
+}
+
+func (m *Manager) scan(full bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, name := range m.order {
+		scan := m.scanners[name].Scan
+		if full {
+			scan = m.scanners[name].Rescan
+		}
+		if _, err := scan(); err != nil {
+			fmt.Printf("Scan error (%s): %v\n", name, err)
+		}
+		if m.retain[name] {
+			m.retainArchive(name)
+		}
+	}
+}
+
+// loadArchive загружает архив источника (дни, которых уже нет в data_dir)
+// и применяет политику хранения
+func (m *Manager) loadArchive(name string) {
+	if m.archiver == nil {
+		return
+	}
+
+	live := make(map[string]bool)
+	for path := range m.scanners[name].GetFiles() {
+		if day, ok := converter.TrimExtension(filepath.Base(path)); ok {
+			live[day] = true
+		}
+	}
+
+	skip := func(day string) bool { return live[day] }
+	if err := m.archiver.Load(name, skip); err != nil {
+		fmt.Printf("Archive error (%s): %v\n", name, err)
+	}
+	m.retainArchive(name)
+}
+
+// retainArchive сжимает старые архивные файлы, если с прошлого раза
+// в архив что-то добавилось
+func (m *Manager) retainArchive(name string) {
+	if m.archiver == nil {
+		return
+	}
+	delete(m.retain, name)
+
+	if err := m.archiver.Retain(name); err != nil {
+		fmt.Printf("Archive retention error (%s): %v\n", name, err)
+	}
+}
+
+// Run сканирует источники с интервалом interval, а раз в fullInterval
+// делает полный проход (Rescan). Блокируется до закрытия stop.
+func (m *Manager) Run(interval, fullInterval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastFull := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if time.Since(lastFull) >= fullInterval {
+				m.Rescan()
+				lastFull = time.Now()
+			} else {
+				m.Scan()
+			}
+		}
+	}
+}
+
+// evictShadowed удаляет из кэша файлы той же даты в менее приоритетных форматах
+// (например, 20240101.json после появления 20240101.db.gz), чтобы день
+// не учитывался дважды
+func (m *Manager) evictShadowed(name, path string) {
+	day, ok := converter.TrimExtension(filepath.Base(path))
+	if !ok {
+		return
+	}
+
+	dir := filepath.Dir(path)
+	lower := false
+	for _, ext := range converter.Extensions {
+		other := filepath.Join(dir, day+ext)
+		if other == path {
+			lower = true
+			continue
+		}
+		if lower {
+			if _, cached := m.cache.Get(cache.Key(name, other)); cached {
+				fmt.Printf("Source %s: %s replaces %s\n", name, filepath.Base(path), filepath.Base(other))
+				m.cache.Delete(cache.Key(name, other))
+			}
+		}
+	}
+}
+
+// DataDir возвращает текущую директорию источника
+func (m *Manager) DataDir(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.scanners[name]; ok {
+		return s.DataDir()
+	}
+	return ""
+}
+
+func (m *Manager) newScanner(name, dataDir string) *scanner.Scanner {
+	s := scanner.New(dataDir)
+	s.OnNewFile(func(path string) {
+		fmt.Printf("New file detected (%s): %s\n", name, path)
+		if err := m.cache.LoadFile(name, path); err != nil {
+			fmt.Printf("Error loading file %s: %v\n", path, err)
+			return
+		}
+		m.evictShadowed(name, path)
+	})
+	s.OnModified(func(path string) {
+		fmt.Printf("File modified (%s): %s\n", name, path)
+		if err := m.cache.LoadFile(name, path); err != nil {
+			fmt.Printf("Error reloading file %s: %v\n", path, err)
+		}
+	})
+	s.OnDeleted(func(path string) {
+		fmt.Printf("File deleted (%s): %s\n", name, path)
+		m.cache.Delete(cache.Key(name, path))
+
+		// Если день есть в архиве - данные остаются доступны из него
+		if m.archiver != nil {
+			day, _ := converter.TrimExtension(filepath.Base(path))
+			if err := m.archiver.LoadDay(name, day); err != nil {
+				fmt.Printf("Archive error (%s): %v\n", name, err)
+			}
+		}
+	})
+	s.OnFrozen(func(path string) {
+		if m.archiver == nil {
+			return
+		}
+		if err := m.archiver.Archive(name, path); err != nil {
+			fmt.Printf("Archive error (%s): %v\n", name, err)
+			return
+		}
+		m.retain[name] = true
+	})
+	return s
+}
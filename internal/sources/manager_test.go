@@ -0,0 +1,133 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nlbw-ui/internal/cache"
+	"nlbw-ui/internal/config"
+	"nlbw-ui/internal/converter"
+)
+
+// exportJSON - выгрузка `nlbw -c json` с одной записью
+const exportJSON = `{"columns":["family","proto","port","mac","ip","conns","rx_bytes","rx_pkts","tx_bytes","tx_pkts"],` +
+	`"data":[[4,"TCP",443,"aa:bb:cc:dd:ee:01","192.168.1.10",1,1000,1,100,1]]}`
+
+func writeExport(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(exportJSON), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func writeDatabase(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := converter.ParseJSON(strings.NewReader(exportJSON))
+	if err != nil {
+		t.Fatalf("ParseJSON failed: %v", err)
+	}
+	records, err := converter.RecordsFromTrafficData(data)
+	if err != nil {
+		t.Fatalf("RecordsFromTrafficData failed: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := converter.New().WriteFile(path, converter.Database{}, records); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+// cachedFiles возвращает имена файлов источника в кэше
+func cachedFiles(c *cache.Cache, source string) map[string]bool {
+	files := make(map[string]bool)
+	for key := range c.GetAll() {
+		if keySource, path := cache.SplitKey(key); keySource == source {
+			files[filepath.Base(path)] = true
+		}
+	}
+	return files
+}
+
+func TestManager_HigherPrecedenceFileEvictsExport(t *testing.T) {
+	dir := t.TempDir()
+	writeExport(t, dir, "20240101.json")
+
+	c := cache.New()
+	m := NewManager(c)
+	if err := m.Apply([]config.SourceConfig{{Name: "main", DataDir: dir}}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if files := cachedFiles(c, "main"); !files["20240101.json"] {
+		t.Fatalf("Expected the json export in cache, got %v", files)
+	}
+
+	dbPath := writeDatabase(t, dir, "20240101.db.gz")
+	jsonPath := filepath.Join(dir, "20240101.json")
+
+	// Загрузка .db.gz сама вытесняет выгрузку за ту же дату
+	c.LoadFile("main", dbPath)
+	m.evictShadowed("main", dbPath)
+	if _, ok := c.Get(cache.Key("main", jsonPath)); ok {
+		t.Error("Lower-precedence export must be evicted")
+	}
+
+	m.Scan()
+	if files := cachedFiles(c, "main"); len(files) != 1 || !files["20240101.db.gz"] {
+		t.Errorf("Expected only 20240101.db.gz after scan, got %v", files)
+	}
+}
+
+func TestManager_Apply(t *testing.T) {
+	mainDir, apDir, movedDir := t.TempDir(), t.TempDir(), t.TempDir()
+	writeExport(t, mainDir, "20240101.json")
+	writeExport(t, apDir, "20240102.json")
+	writeExport(t, movedDir, "20240103.json")
+
+	c := cache.New()
+	m := NewManager(c)
+
+	// Новые источники сканируются сразу
+	err := m.Apply([]config.SourceConfig{
+		{Name: "main", DataDir: mainDir},
+		{Name: "ap", DataDir: apDir},
+	})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if files := cachedFiles(c, "ap"); !files["20240102.json"] {
+		t.Fatalf("Expected ap files in cache, got %v", files)
+	}
+
+	// Смена data_dir перестраивает кэш источника
+	err = m.Apply([]config.SourceConfig{
+		{Name: "main", DataDir: mainDir},
+		{Name: "ap", DataDir: movedDir},
+	})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if files := cachedFiles(c, "ap"); len(files) != 1 || !files["20240103.json"] {
+		t.Errorf("Expected only files of the new data_dir, got %v", files)
+	}
+	if m.DataDir("ap") != movedDir {
+		t.Errorf("Expected data_dir %s, got %s", movedDir, m.DataDir("ap"))
+	}
+
+	// Удалённый источник выбрасывается из кэша, остальные не трогаются
+	if err := m.Apply([]config.SourceConfig{{Name: "main", DataDir: mainDir}}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if files := cachedFiles(c, "ap"); len(files) != 0 {
+		t.Errorf("Expected removed source to be cleared, got %v", files)
+	}
+	if m.DataDir("ap") != "" {
+		t.Error("Removed source must have no scanner")
+	}
+	if files := cachedFiles(c, "main"); !files["20240101.json"] {
+		t.Errorf("Unchanged source must keep its files, got %v", files)
+	}
+}
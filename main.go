@@ -5,13 +5,18 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"nlbw-ui/internal/api"
+	"nlbw-ui/internal/archive"
 	"nlbw-ui/internal/cache"
 	"nlbw-ui/internal/config"
 	"nlbw-ui/internal/demo"
-	"nlbw-ui/internal/scanner"
+	"nlbw-ui/internal/live"
+	"nlbw-ui/internal/sources"
 )
 
 //go:embed frontend/dist
@@ -22,16 +27,24 @@ func main() {
 	configPath := flag.String("config", "config.yaml", "Path to config file")
 	flag.StringVar(configPath, "c", "config.yaml", "Path to config file (shorthand)")
 	demoFlag := flag.String("demo", "", "Generate demo data for date range (format: DD.MM.YYYY-DD.MM.YYYY)")
+	demoSeed := flag.Int64("demo-seed", 0, "Seed for reproducible demo data (0 = random)")
+	demoScenario := flag.String("demo-scenario", "", "YAML file describing demo devices (see demo-scenario.yaml.example)")
 	flag.Parse()
 
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	printWarnings(nil, cfg)
 
 	dataCache := cache.New()
 
+	// fatalf - log.Fatalf, в demo режиме перед выходом удаляющий временную директорию
+	fatalf := log.Fatalf
+
 	// Проверяем, включен ли demo режим
+	var demoDir string
+	var demoNames map[string]string
 	if *demoFlag != "" {
 		fmt.Println("Demo mode enabled!")
 		fmt.Printf("Generating demo data for range: %s\n", *demoFlag)
@@ -42,70 +55,205 @@ func main() {
 			log.Fatalf("Invalid date range: %v", err)
 		}
 
-		// Создаем генератор
-		generator := demo.NewGenerator()
+		// Demo данные пишутся настоящими файлами nlbwmon во временную
+		// директорию и читаются обычным сканером
+		demoDir, err = os.MkdirTemp("", "nlbw-ui-demo-")
+		if err != nil {
+			log.Fatalf("Failed to create demo directory: %v", err)
+		}
+		fatalf = func(format string, v ...interface{}) {
+			os.RemoveAll(demoDir)
+			log.Fatalf(format, v...)
+		}
+
+		// Создаем генератор: с одним seed и сценарием данные совпадают
+		var scenario *demo.Scenario
+		if *demoScenario != "" {
+			scenario, err = demo.LoadScenario(*demoScenario)
+			if err != nil {
+				fatalf("Failed to load demo scenario: %v", err)
+			}
+		}
+		seed := *demoSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		generator := demo.NewGeneratorWithSeed(seed, scenario)
+		fmt.Printf("Demo seed: %d (use --demo-seed to reproduce)\n", seed)
+
+		demoNames = generator.Names()
+		cfg = demoConfig(cfg, demoDir, demoNames)
 
 		// Генерируем данные для каждого дня в диапазоне
 		dates := dateRange.GetAllDates()
 		fmt.Printf("Generating data for %d days...\n", len(dates))
 
+		today := demo.FormatDateForFilename(time.Now())
+		liveToday := false
 		for _, date := range dates {
-			trafficData := generator.GenerateForDate(date)
-
-			// Формируем имя "файла" для кэша
-			filename := demo.FormatDateForFilename(date) + ".db.gz"
-			virtualPath := fmt.Sprintf("demo/%s", filename)
-
-			dataCache.Set(virtualPath, trafficData)
-		}
-
-		fmt.Printf("Generated data for %d days\n", len(dates))
-	} else {
-		// Обычный режим - сканирование файлов
-		fileScanner := scanner.New(cfg.DataDir)
-		fileScanner.OnNewFile(func(path string) {
-			fmt.Printf("New file detected: %s\n", path)
-			if err := dataCache.LoadFile(path); err != nil {
-				fmt.Printf("Error loading file %s: %v\n", path, err)
+			// Сегодняшний файл "растёт" в течение дня, его ведёт LiveDay
+			if demo.FormatDateForFilename(date) == today {
+				liveToday = true
+				continue
 			}
-		})
-		fileScanner.OnModified(func(path string) {
-			fmt.Printf("File modified: %s\n", path)
-			if err := dataCache.LoadFile(path); err != nil {
-				fmt.Printf("Error reloading file %s: %v\n", path, err)
+			if err := demo.WriteDay(demoDir, date, generator.GenerateForDate(date)); err != nil {
+				fatalf("Failed to write demo data: %v", err)
 			}
-		})
+		}
 
-		fmt.Println("Performing initial scan...")
-		if _, err := fileScanner.Scan(); err != nil {
-			log.Fatalf("Initial scan failed: %v", err)
+		if liveToday {
+			liveDay := demo.NewLiveDay(generator, demoDir)
+			if err := liveDay.Update(); err != nil {
+				fatalf("Failed to write demo data: %v", err)
+			}
+			go liveDay.Run(30*time.Second, nil)
 		}
 
-		go func() {
-			ticker := time.NewTicker(10 * time.Second)
-			defer ticker.Stop()
+		fmt.Printf("Generated data for %d days in %s\n", len(dates), demoDir)
 
-			for range ticker.C {
-				if _, err := fileScanner.Scan(); err != nil {
-					fmt.Printf("Scan error: %v\n", err)
-				}
-			}
+		// Временная директория удаляется при остановке
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-stop
+			os.RemoveAll(demoDir)
+			os.Exit(0)
 		}()
 	}
 
+	// Сканирование файлов (в demo режиме - сгенерированных)
+	sourceManager := sources.NewManager(dataCache)
+	if cfg.Archive.Enabled() {
+		sourceManager.SetArchiver(archive.New(cfg.Archive, dataCache))
+	}
+
+	fmt.Println("Performing initial scan...")
+	if err := sourceManager.Apply(cfg.Sources); err != nil {
+		fatalf("Initial scan failed: %v", err)
+	}
+
+	// Раз в 5 минут - полный проход с проверкой замороженных файлов
+	go sourceManager.Run(10*time.Second, 5*time.Minute, nil)
+
+	// Опрос работающего nlbwmon для данных за сегодня
+	if cfg.Live.Enabled {
+		liveSource := cfg.Live.Source
+		dataDir := func() string { return sourceManager.DataDir(liveSource) }
+		poller := live.NewPoller(live.NewSource(cfg.Live), dataCache, liveSource, dataDir, cfg.Live.Interval)
+		go poller.Run(nil)
+	}
+
 	server := api.New(dataCache, cfg, frontendFS)
 	addr := fmt.Sprintf("%s:%d", cfg.ServerAddress, cfg.ServerPort)
 
+	// Перезагрузка конфига: изменение файла, SIGHUP или POST /api/admin/reload
+	watcher := config.NewWatcher(*configPath, cfg, 5*time.Second)
+	applied := cfg
+	watcher.OnChange(func(_, newCfg *config.Config) {
+		if demoDir != "" {
+			newCfg = demoConfig(newCfg, demoDir, demoNames)
+		}
+		applyConfig(applied, newCfg, server, sourceManager)
+		applied = newCfg
+	})
+	server.OnReload(func() error {
+		_, err := watcher.Reload()
+		return err
+	})
+	go watcher.Run(nil)
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			fmt.Println("SIGHUP received, reloading config...")
+			if _, err := watcher.Reload(); err != nil {
+				fmt.Printf("Config reload failed, keeping previous config: %v\n", err)
+			}
+		}
+	}()
+
 	fmt.Printf("\nNLBW-UI is running!\n")
 	fmt.Printf("- Web UI: http://localhost:%d\n", cfg.ServerPort)
 	fmt.Printf("- API: http://localhost:%d/api\n", cfg.ServerPort)
 	if *demoFlag != "" {
 		fmt.Printf("- Mode: DEMO (data range: %s)\n\n", *demoFlag)
 	} else {
-		fmt.Printf("- Scanning: %s\n\n", cfg.DataDir)
+		for _, src := range cfg.Sources {
+			fmt.Printf("- Scanning (%s): %s\n", src.Name, src.DataDir)
+		}
+		if cfg.Live.Enabled {
+			fmt.Printf("- Live: every %s\n", cfg.Live.Interval)
+		}
+		if cfg.Archive.Enabled() {
+			fmt.Printf("- Archive: %s\n", cfg.Archive.Dir)
+		}
+		fmt.Println()
 	}
 
 	if err := server.Start(addr); err != nil {
-		log.Fatalf("Server failed: %v", err)
+		fatalf("Server failed: %v", err)
+	}
+}
+
+// applyConfig применяет перезагруженный конфиг к работающему приложению
+func applyConfig(oldCfg, newCfg *config.Config, server *api.Server, sourceManager *sources.Manager) {
+	printWarnings(oldCfg, newCfg)
+	server.SetConfig(newCfg)
+
+	if err := sourceManager.Apply(newCfg.Sources); err != nil {
+		fmt.Printf("Scan error: %v\n", err)
+	}
+
+	if newCfg.ServerAddress != oldCfg.ServerAddress || newCfg.ServerPort != oldCfg.ServerPort {
+		addr := fmt.Sprintf("%s:%d", newCfg.ServerAddress, newCfg.ServerPort)
+		if err := server.Rebind(addr); err != nil {
+			fmt.Printf("Failed to move server to %s: %v\n", addr, err)
+		}
+	}
+
+	if newCfg.Live != oldCfg.Live {
+		fmt.Println("Warning: live settings changed, restart required to apply them")
+	}
+
+	if newCfg.Archive != oldCfg.Archive {
+		fmt.Println("Warning: archive settings changed, restart required to apply them")
+	}
+
+	fmt.Println("Config reloaded")
+}
+
+// demoConfig подменяет источники конфига директорией с demo данными
+// и добавляет имена устройств сценария (имена из конфига важнее).
+// Живой опрос nlbwmon и архив в demo режиме отключены.
+func demoConfig(cfg *config.Config, dir string, names map[string]string) *config.Config {
+	demoCfg := *cfg
+	demoCfg.FriendlyNames = make(map[string]string, len(names)+len(cfg.FriendlyNames))
+	for mac, name := range names {
+		demoCfg.FriendlyNames[mac] = name
+	}
+	for mac, name := range cfg.FriendlyNames {
+		demoCfg.FriendlyNames[mac] = name
+	}
+	demoCfg.DataDir = dir
+	demoCfg.Sources = []config.SourceConfig{{Name: "demo", Type: config.SourceLocal, DataDir: dir}}
+	demoCfg.Live = config.LiveConfig{}
+	demoCfg.Archive = config.ArchiveConfig{}
+	return &demoCfg
+}
+
+// printWarnings выводит замечания к конфигу, которых не было в прежнем (oldCfg может быть nil),
+// чтобы одно и то же предупреждение не повторялось при каждой перезагрузке
+func printWarnings(oldCfg, newCfg *config.Config) {
+	seen := make(map[string]bool)
+	if oldCfg != nil {
+		for _, warning := range oldCfg.Warnings {
+			seen[warning] = true
+		}
+	}
+	for _, warning := range newCfg.Warnings {
+		if !seen[warning] {
+			fmt.Printf("Warning: %s\n", warning)
+		}
 	}
 }